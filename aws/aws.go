@@ -0,0 +1,9 @@
+// Package aws holds the cloudformation templates and front proxy assets deployed by the aws provider
+package aws
+
+import "embed"
+
+// Files are the templates and front proxy assets, paths are relative to this folder, e.g. front-proxy/app.yml
+//
+//go:embed *.yml front-proxy
+var Files embed.FS
@@ -17,29 +17,63 @@ package cmd
 
 import (
 	"fmt"
+	units "github.com/docker/go-units"
 	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/provider/docker"
+	"github.com/kahgeh/devenv/provider/manifest"
 	"github.com/kahgeh/devenv/provider/types"
 	"os"
 	"runtime/debug"
+	"strings"
 
 	"github.com/kahgeh/devenv/logger"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 const (
-	argPath    cmdTypes.ArgName = "path"
-	argAppType cmdTypes.ArgName = "type"
+	argPath       cmdTypes.ArgName = "path"
+	argAppType    cmdTypes.ArgName = "type"
+	argImage      cmdTypes.ArgName = "image"
+	argTag        cmdTypes.ArgName = "tag"
+	argDockerfile cmdTypes.ArgName = "dockerfile"
+	argTarget     cmdTypes.ArgName = "target"
+	argBuildArg   cmdTypes.ArgName = "build-arg"
+	argCache      cmdTypes.ArgName = "cache"
+	argNoCache    cmdTypes.ArgName = "no-cache"
+	argPull       cmdTypes.ArgName = "pull"
+	argPlatform   cmdTypes.ArgName = "platform"
+	// argContextSizeWarning is read with viper so that it can be kept in the config file
+	argContextSizeWarning cmdTypes.ArgName = "context-size-warning"
 )
 
+// buildFlagNames are the flags that only apply when deploy builds the image
+var buildFlagNames = []cmdTypes.ArgName{argDockerfile, argTarget, argBuildArg, argCache, argNoCache, argPull, argPlatform}
+
+// platforms are the ones the instances of the environment can run
+var platforms = []string{"linux/amd64", "linux/arm64"}
+
+// appFlags are shared by deploy and rollback, both read the devenv.yaml of the app
+var appFlags = newAppFlags()
+
+func newAppFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("app", pflag.ContinueOnError)
+	flags.String(string(argPath), ".", "--path <relative or absolute path>")
+	return flags
+}
+
 // deployCmd represents the deploy command
 var deployCmd = &cobra.Command{
 	Use:   "deploy <name>",
 	Short: "deploy service",
-	Long: `deploy service
+	Long: `deploy service, the devenv.yaml next to the Dockerfile sets the container port, cpu and memory reservation,
+environment variables, desired count, health check and route prefix of the app
 when
-	type is front-proxy, deploy will recreate the stack, also it will use http ports 80, 443`,
-	Run: deploy,
+	type is front-proxy, deploy will recreate the stack, also it will use http ports 80, 443
+	image or tag is set, the image is deployed as is instead of being built, aws checks it exists in ecr
+	no-cache is set, every layer is built again, by default the layers of earlier builds are reused`,
+	RunE: deploy,
 }
 
 func extractParameters(args []string) (appName string, appType types.AppType, path string, envName string, domainName string, domainEmail string, err error) {
@@ -65,36 +99,138 @@ func extractParameters(args []string) (appName string, appType types.AppType, pa
 	return
 }
 
-func deploy(_ *cobra.Command, args []string) {
+func deploy(command *cobra.Command, args []string) (err error) {
 	startLog()
 	log := logger.New()
 	defer log.LogDone()
 	defer func() {
-		if err := recover(); err != nil {
-			log.Failf("%v", err)
-			log.Debugf("stacktrace : \n %v" + string(debug.Stack()))
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
 		}
 	}()
 
 	appName, appType, path, envName, domainName, domainEmail, err := extractParameters(args)
 	if err != nil {
-		log.Fail(err.Error())
+		return err
 	}
 
-	createSession().Deploy(&types.DeployParameters{
+	// read from the flags, viper would also pick up the IMAGE and TAG environment variables ci jobs often set
+	image, _ := command.Flags().GetString(string(argImage))
+	tag, _ := command.Flags().GetString(string(argTag))
+	if image != "" && tag != "" {
+		return types.NewUserError("deploy", fmt.Errorf("use either --image or --tag"))
+	}
+
+	buildOptions, err := getBuildOptions(command, appType, path)
+	if err != nil {
+		return err
+	}
+	if image != "" || tag != "" {
+		for _, name := range buildFlagNames {
+			if command.Flags().Changed(string(name)) {
+				return types.NewUserError("deploy", fmt.Errorf("--%s cannot be used with --image or --tag, the image is not built", name))
+			}
+		}
+	}
+
+	var appManifest *manifest.Manifest
+	if appType != types.FrontProxy {
+		appManifest, err = manifest.Read(path)
+		if err != nil {
+			return types.NewUserError(fmt.Sprintf("read %s", manifest.FileName), err)
+		}
+	}
+
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	return session.Deploy(&types.DeployParameters{
 		AppType:         appType,
 		AppName:         appName,
 		Path:            path,
 		DomainName:      domainName,
 		DomainEmail:     domainEmail,
 		EnvironmentName: envName,
+		Manifest:        appManifest,
+		Image:           image,
+		Tag:             tag,
+		Build:           *buildOptions,
 	})
 }
 
+// getBuildOptions reads the build flags, from the flags only as viper would also pick up environment variables
+// such as TARGET or PLATFORM
+func getBuildOptions(command *cobra.Command, appType types.AppType, path string) (*types.BuildOptions, error) {
+	flags := command.Flags()
+	dockerfile, _ := flags.GetString(string(argDockerfile))
+	target, _ := flags.GetString(string(argTarget))
+	buildArgs, _ := flags.GetStringArray(string(argBuildArg))
+	cache, _ := flags.GetBool(string(argCache))
+	noCache, _ := flags.GetBool(string(argNoCache))
+	pull, _ := flags.GetBool(string(argPull))
+	platform, _ := flags.GetString(string(argPlatform))
+
+	if flags.Changed(string(argCache)) && flags.Changed(string(argNoCache)) {
+		return nil, types.NewUserError("read build options", fmt.Errorf("use either --cache or --no-cache"))
+	}
+	if appType == types.FrontProxy && (dockerfile != "" || target != "") {
+		return nil, types.NewUserError("read build options",
+			fmt.Errorf("--dockerfile and --target cannot be used with the built in front proxy Dockerfile"))
+	}
+	contextSizeWarning, err := units.FromHumanSize(viper.GetString(string(argContextSizeWarning)))
+	if err != nil {
+		return nil, types.NewUserError("read build options", fmt.Errorf("--%s %v", argContextSizeWarning, err))
+	}
+	options := &types.BuildOptions{
+		Target:             target,
+		BuildArgs:          map[string]string{},
+		NoCache:            noCache || !cache,
+		Pull:               pull,
+		Platform:           platform,
+		ContextSizeWarning: contextSizeWarning,
+	}
+	if dockerfile != "" {
+		resolved, err := docker.ResolveDockerfile(path, dockerfile)
+		if err != nil {
+			return nil, types.NewUserError(fmt.Sprintf("read dockerfile %s", dockerfile), err)
+		}
+		options.Dockerfile = resolved
+	}
+	for _, buildArg := range buildArgs {
+		index := strings.Index(buildArg, "=")
+		if index <= 0 {
+			return nil, types.NewUserError(fmt.Sprintf("read build arg %q", buildArg), fmt.Errorf("expected KEY=VALUE"))
+		}
+		options.BuildArgs[buildArg[:index]] = buildArg[index+1:]
+	}
+	if platform == "" {
+		return options, nil
+	}
+	for _, supported := range platforms {
+		if platform == supported {
+			return options, nil
+		}
+	}
+	return nil, types.NewUserError(fmt.Sprintf("read platform %q", platform),
+		fmt.Errorf("expected one of %s", strings.Join(platforms, ", ")))
+}
+
 func init() {
 	rootCmd.AddCommand(deployCmd)
-	deployCmd.PersistentFlags().String(string(argPath), ".", "--path <relative or absolute path>")
+	deployCmd.PersistentFlags().AddFlagSet(appFlags)
 	deployCmd.PersistentFlags().String(string(argAppType), "api", "--type [default is api - other options include front-proxy]")
+	deployCmd.PersistentFlags().String(string(argImage), "", "--image <account>.dkr.ecr.<region>.amazonaws.com/<repository>:<tag> deploys an image pushed before")
+	deployCmd.PersistentFlags().String(string(argTag), "", "--tag <tag> deploys an image of the app repository pushed by an earlier deploy")
+	deployCmd.PersistentFlags().String(string(argDockerfile), "", "--dockerfile <path> of the Dockerfile inside --path, relative to it (default is Dockerfile)")
+	deployCmd.PersistentFlags().String(string(argTarget), "", "--target <stage> of a multi-stage Dockerfile to build")
+	deployCmd.PersistentFlags().StringArray(string(argBuildArg), nil, "--build-arg KEY=VALUE, repeat it to pass several")
+	deployCmd.PersistentFlags().Bool(string(argCache), true, "--cache reuses the layers of earlier builds")
+	deployCmd.PersistentFlags().Bool(string(argNoCache), false, "--no-cache builds every layer again")
+	deployCmd.PersistentFlags().Bool(string(argPull), false, "--pull pulls newer versions of the base images")
+	deployCmd.PersistentFlags().String(string(argPlatform), "", "--platform linux/amd64|linux/arm64 (default is the platform of the docker daemon)")
+	deployCmd.PersistentFlags().String(string(argContextSizeWarning), "100MB", "--context-size-warning 500MB warns when the build context sent to docker is larger, 0 never warns")
 	err := viper.BindPFlags(deployCmd.PersistentFlags())
 	if err != nil {
 		fmt.Printf("fail to bind command arguments\n %s", err.Error())
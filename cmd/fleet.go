@@ -0,0 +1,90 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const (
+	argInstanceType cmdTypes.ArgName = "instance-type"
+	argSpotPrice    cmdTypes.ArgName = "spot-price"
+	argCapacity     cmdTypes.ArgName = "capacity"
+)
+
+// fleetFlags are shared by init and start, the values passed are saved to the config file for the next commands
+var fleetFlags = newFleetFlags()
+
+func newFleetFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("fleet", pflag.ContinueOnError)
+	flags.String(string(argInstanceType), "m5ad.large", "--instance-type m5ad.large")
+	flags.String(string(argSpotPrice), "0.10", "--spot-price 0.10 is the maximum hourly price of an instance")
+	flags.Int64(string(argCapacity), 1, "--capacity 2 is the number of instances start runs")
+	return flags
+}
+
+// saveFleetSettings writes the fleet flags that were passed to the config file,
+// a viper of its own keeps the other flags out of the file
+func saveFleetSettings() error {
+	var changed []*pflag.Flag
+	fleetFlags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed {
+			changed = append(changed, flag)
+		}
+	})
+	// a plan only previews the settings
+	if len(changed) == 0 || viper.GetBool(string(cmdTypes.ArgPlan)) {
+		return nil
+	}
+	settings := viper.New()
+	settings.SetConfigFile(viper.ConfigFileUsed())
+	if err := settings.ReadInConfig(); err != nil {
+		return err
+	}
+	for _, flag := range changed {
+		settings.Set(flag.Name, viper.Get(flag.Name))
+	}
+	return settings.WriteConfig()
+}
+
+// getFleetParameters validates the fleet settings and saves the ones that were passed
+func getFleetParameters() (types.FleetParameters, error) {
+	fleet := types.FleetParameters{
+		InstanceType: viper.GetString(string(argInstanceType)),
+		SpotPrice:    viper.GetString(string(argSpotPrice)),
+		Capacity:     viper.GetInt64(string(argCapacity)),
+	}
+	if fleet.InstanceType == "" {
+		return fleet, types.NewUserError("read the fleet settings", fmt.Errorf("--instance-type is required"))
+	}
+	if price, err := strconv.ParseFloat(fleet.SpotPrice, 64); err != nil || price <= 0 {
+		return fleet, types.NewUserError("read the fleet settings",
+			fmt.Errorf("--spot-price %q must be a positive hourly price such as 0.10", fleet.SpotPrice))
+	}
+	if fleet.Capacity < 1 {
+		return fleet, types.NewUserError("read the fleet settings", fmt.Errorf("--capacity must be at least 1"))
+	}
+	if err := saveFleetSettings(); err != nil {
+		return fleet, types.NewError(fmt.Sprintf("save the fleet settings to %s", viper.ConfigFileUsed()), err)
+	}
+	return fleet, nil
+}
@@ -28,18 +28,11 @@ import (
 	"runtime/debug"
 )
 
-const (
-	baseUrl string = "https://raw.githubusercontent.com/kahgeh/devenv/master"
-)
 const (
 	argDiscoveryServiceVersion cmdTypes.ArgName = "discovery-service-version"
 )
 
-var cloudProviderFiles = map[string][]string{"aws": {"vpc.yml", "ecsCluster.yml",
-	"spotFleet.yml", "publicIp.yml",
-	"ecr.yml", "app.yml", "front-proxy/app.yml", "front-proxy/Dockerfile", "front-proxy/init-and-run.sh", "front-proxy/envoy-template.py"}}
-
-func initialiseConfig() {
+func initialiseConfig() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 
@@ -47,31 +40,13 @@ func initialiseConfig() {
 	log.Infof("ensuring config folder '%s' exists...", configFolderPath)
 	err := utils.CreateFolderIfNotExist(configFolderPath)
 	if err != nil {
-		log.Debug(err.Error())
-		log.Failf("fail to ensure %q exist", configFolderPath)
-	}
-	cloudProvider := "aws"
-	cloudProviderFolderPath := fmt.Sprintf("%v/%v", configFolderPath, cloudProvider)
-	log.Infof("ensuring provider config folder '%s' exists...", cloudProviderFolderPath)
-	err = utils.CreateFolderIfNotExist(cloudProviderFolderPath)
-	if err != nil {
-		log.Debug(err.Error())
-		log.Failf("fail to ensure %q exist", cloudProviderFolderPath)
-	}
-	frontProxyFolderPath := fmt.Sprintf("%v/front-proxy", cloudProviderFolderPath)
-	log.Infof("ensuring front proxy folder '%s' exists...", frontProxyFolderPath)
-	err = utils.CreateFolderIfNotExist(frontProxyFolderPath)
-	if err != nil {
-		log.Debug(err.Error())
-		log.Failf("fail to ensure %q exist", frontProxyFolderPath)
-	}
-
-	log.Info("downloading cloud provider files...")
-	for _, fileName := range cloudProviderFiles[cloudProvider] {
-		cloudProviderFilePath := fmt.Sprintf("%v/%v", cloudProvider, fileName)
-		download(baseUrl, fileName, cloudProviderFilePath)
+		err = types.NewError(fmt.Sprintf("ensure %q exist", configFolderPath), err)
+		log.Fail(err)
+		return err
 	}
+	log.Info("templates are built in, run devenv templates export to customise them...")
 	log.Succeed()
+	return nil
 }
 
 // initCmd represents the init command
@@ -79,7 +54,7 @@ var initCmd = &cobra.Command{
 	Use:   "init [config]",
 	Short: "initialises a vpc, spotfleet and an ecs cluster",
 	Long:  `initialises infrastructure, does not cost to keep it`,
-	Run:   initialise,
+	RunE:  initialise,
 }
 
 func extractInitParameters() (domainName string, domainEmail string, envName string, discoveryServiceVersion string) {
@@ -90,35 +65,47 @@ func extractInitParameters() (domainName string, domainEmail string, envName str
 	return
 }
 
-func initialise(_ *cobra.Command, args []string) {
+func initialise(_ *cobra.Command, args []string) (err error) {
 	startLog()
 	log := logger.New()
 	defer log.LogDone()
 	defer func() {
-		if err := recover(); err != nil {
-			log.Failf("%v", err)
-			log.Debugf("stacktrace : \n %v" + string(debug.Stack()))
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
 		}
 	}()
 
 	if len(args) == 1 && args[0] == "config" {
-		initialiseConfig()
-		return
+		return initialiseConfig()
 	}
 
 	domainName, domainEmail, envName, discoveryServiceVersion := extractInitParameters()
-	createSession().Initialise(&types.InitialisationParameters{
+	fleet, err := getFleetParameters()
+	if err != nil {
+		return err
+	}
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	return session.Initialise(&types.InitialisationParameters{
 		DomainName:              domainName,
 		DomainEmail:             domainEmail,
 		EnvironmentName:         envName,
 		DiscoveryServiceVersion: discoveryServiceVersion,
+		Fleet:                   fleet,
 	})
 }
 
 func init() {
 	rootCmd.AddCommand(initCmd)
 	deployCmd.PersistentFlags().String(string(argDiscoveryServiceVersion), "0.0.1", "--discovery-service-version <relative or absolute path>")
+	initCmd.PersistentFlags().AddFlagSet(fleetFlags)
 	err := viper.BindPFlags(deployCmd.PersistentFlags())
+	if err == nil {
+		err = viper.BindPFlags(initCmd.PersistentFlags())
+	}
 	if err != nil {
 		fmt.Printf("fail to bind command arguments\n %s", err.Error())
 		os.Exit(logger.ExitFailureStatus)
@@ -0,0 +1,99 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	argSince    cmdTypes.ArgName = "since"
+	argFollow   cmdTypes.ArgName = "follow"
+	argFilter   cmdTypes.ArgName = "filter"
+	argInstance cmdTypes.ArgName = "instance"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <name>",
+	Short: "show the logs of an app",
+	Long: `show the logs of an app, events of all its streams are ordered by time
+when
+	instance is set, the logs of the environment instances are shown instead`,
+	RunE: logs,
+}
+
+func logs(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	instance := viper.GetBool(string(argInstance))
+	appName := ""
+	if !instance {
+		if len(args) < 1 {
+			return &cmdTypes.MissingArgument{ParameterName: "appName"}
+		}
+		appName = args[0]
+	}
+
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	logReader, ok := session.(provider.LogReader)
+	if !ok {
+		return types.NewUserError("show logs",
+			fmt.Errorf("logs are not supported by the %s provider", viper.GetString(string(cmdTypes.ArgProvider))))
+	}
+	return logReader.Logs(&types.LogsParameters{
+		AppName:         appName,
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		Instance:        instance,
+		Since:           viper.GetDuration(string(argSince)),
+		Follow:          viper.GetBool(string(argFollow)),
+		Filter:          viper.GetString(string(argFilter)),
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.PersistentFlags().Duration(string(argSince), 10*time.Minute, "--since 1h shows the logs of the last hour")
+	logsCmd.PersistentFlags().BoolP(string(argFollow), "f", false, "--follow keeps showing new logs until interrupted")
+	logsCmd.PersistentFlags().String(string(argFilter), "", "--filter <cloudwatch logs filter pattern>")
+	logsCmd.PersistentFlags().Bool(string(argInstance), false, "--instance shows the logs of the environment instances")
+	err := viper.BindPFlags(logsCmd.PersistentFlags())
+	if err != nil {
+		fmt.Printf("fail to bind command arguments\n %s", err.Error())
+		os.Exit(logger.ExitFailureStatus)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"text/tabwriter"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// releasesCmd represents the releases command
+var releasesCmd = &cobra.Command{
+	Use:   "releases <app>",
+	Short: "list the releases of an app",
+	Long: `list the releases of an app newest first, each deploy records the image tag, time, git commit and deployer,
+the release the app runs is marked with *`,
+	RunE: releases,
+}
+
+func getReleaseHistory() (provider.ReleaseHistory, error) {
+	session, err := createSession()
+	if err != nil {
+		return nil, err
+	}
+	history, ok := session.(provider.ReleaseHistory)
+	if !ok {
+		return nil, types.NewUserError("read releases",
+			fmt.Errorf("releases are not supported by the %s provider", viper.GetString(string(cmdTypes.ArgProvider))))
+	}
+	return history, nil
+}
+
+func printReleasesTable(writer io.Writer, releases []types.Release) {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "\tTAG\tDEPLOYED\tGIT SHA\tDEPLOYER")
+	for _, release := range releases {
+		current := ""
+		if release.Current {
+			current = "*"
+		}
+		gitSha := release.GitSha
+		if len(gitSha) > 12 {
+			gitSha = gitSha[:12]
+		}
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", current, release.Tag,
+			release.Timestamp.Local().Format("2006-01-02 15:04:05"), gitSha, release.Deployer)
+	}
+	_ = table.Flush()
+}
+
+func releases(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	if len(args) < 1 {
+		return &cmdTypes.MissingArgument{ParameterName: "appName"}
+	}
+	history, err := getReleaseHistory()
+	if err != nil {
+		return err
+	}
+	appReleases, err := history.Releases(&types.ReleasesParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		AppName:         args[0],
+	})
+	if err != nil {
+		return err
+	}
+	log.Result("releases", appReleases)
+	if cmdTypes.OutputFormat(viper.GetString(string(cmdTypes.ArgOutput))) == cmdTypes.OutputFormatJSON {
+		return nil
+	}
+	if len(appReleases) == 0 {
+		log.Print(fmt.Sprintf("%s has no releases\n", args[0]))
+		return nil
+	}
+	printReleasesTable(os.Stdout, appReleases)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(releasesCmd)
+}
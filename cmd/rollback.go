@@ -0,0 +1,99 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/manifest"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const argTo cmdTypes.ArgName = "to"
+
+// rollbackCmd represents the rollback command
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <app>",
+	Short: "deploy an earlier release of an app again",
+	Long: `deploy an earlier release of an app again without building it, the image is deployed with the devenv.yaml it was
+released with, releases recorded without it use the devenv.yaml in path
+when
+	to is not set, the release deployed before the one the app runs is deployed
+	to is set, the release with that tag is deployed, see devenv releases <app>`,
+	RunE: rollback,
+}
+
+func rollback(command *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	if len(args) < 1 {
+		return &cmdTypes.MissingArgument{ParameterName: "appName"}
+	}
+	appName := args[0]
+	// read from the flag, viper would also pick up a TO environment variable
+	to, _ := command.Flags().GetString(string(argTo))
+
+	var appManifest *manifest.Manifest
+	if appName != string(cmdTypes.KnownAppFrontProxy) {
+		appManifest, err = manifest.Read(viper.GetString(string(argPath)))
+		if err != nil {
+			return types.NewUserError(fmt.Sprintf("read %s", manifest.FileName), err)
+		}
+	}
+
+	history, err := getReleaseHistory()
+	if err != nil {
+		return err
+	}
+	release, err := history.Rollback(&types.RollbackParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		AppName:         appName,
+		To:              to,
+		DomainName:      viper.GetString(string(cmdTypes.ArgDomainName)),
+		Manifest:        appManifest,
+	})
+	if err != nil || viper.GetBool(string(cmdTypes.ArgPlan)) {
+		return err
+	}
+	log.Print(fmt.Sprintf("%s rolled back to %s deployed %s\n", appName, release.Tag,
+		release.Timestamp.Local().Format("2006-01-02 15:04:05")))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	rollbackCmd.PersistentFlags().AddFlagSet(appFlags)
+	rollbackCmd.PersistentFlags().String(string(argTo), "", "--to <tag> of the release to deploy, the one before the current release by default")
+	err := viper.BindPFlags(rollbackCmd.PersistentFlags())
+	if err != nil {
+		fmt.Printf("fail to bind command arguments\n %s", err.Error())
+		os.Exit(logger.ExitFailureStatus)
+	}
+}
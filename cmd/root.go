@@ -19,14 +19,13 @@ import (
 	"bufio"
 	"fmt"
 	"github.com/kahgeh/devenv/cmd/types"
-	"github.com/kahgeh/devenv/utils"
-	"io"
-	"net/http"
 	"os"
+	"strings"
 
 	"github.com/kahgeh/devenv/fixed"
 	"github.com/kahgeh/devenv/logger"
 	"github.com/kahgeh/devenv/provider"
+	providerTypes "github.com/kahgeh/devenv/provider/types"
 	"github.com/spf13/cobra"
 
 	"github.com/spf13/viper"
@@ -38,6 +37,9 @@ var cfgFile string
 var rootCmd = &cobra.Command{
 	Use:   "devenv",
 	Short: "Tool to deploy apps to personal dev environments",
+	// errors are reported by Execute, once every task has completed
+	SilenceErrors: true,
+	SilenceUsage:  true,
 	Long: `
 		- init creates 
 			- vpc 
@@ -55,13 +57,43 @@ var rootCmd = &cobra.Command{
 			- httpapi (will start if not started and init if not available`,
 }
 
+const (
+	exitUserError   = 2
+	exitAwsError    = 4
+	exitDockerError = 5
+)
+
+// commandStarted tells apart the errors of a command from cobra rejecting the flags or arguments
+var commandStarted bool
+
+// exitCode tells scripts who can act on the error
+func exitCode(err error) int {
+	switch providerTypes.GetErrorKind(err) {
+	case providerTypes.UserErrorKind:
+		return exitUserError
+	case providerTypes.AwsErrorKind:
+		return exitAwsError
+	case providerTypes.DockerErrorKind:
+		return exitDockerError
+	default:
+		return logger.ExitFailureStatus
+	}
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
-func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// It returns the exit code of the command.
+func Execute() int {
+	executedCmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return 0
 	}
+	logger.ReportError(err)
+	if !commandStarted {
+		fmt.Fprintf(os.Stderr, "Run '%s --help' for usage.\n", executedCmd.CommandPath())
+		return exitUserError
+	}
+	return exitCode(err)
 }
 
 var loglevelMap = map[string]logger.LogLevel{
@@ -70,51 +102,32 @@ var loglevelMap = map[string]logger.LogLevel{
 }
 
 func startLog() {
+	commandStarted = true
 	loglevelChoice := viper.GetString("loglevel")
 	var loglevel logger.LogLevel = logger.NormalLogLevel
 	if len(loglevelChoice) > 0 {
 		loglevel = loglevelMap[loglevelChoice]
 	}
-	logger.CreateLogger(loglevel)
-}
-
-func download(baseUrl string, fileName string, outputPath string) bool {
-	log := logger.New()
-	defer log.LogDone()
-	sourceUrl := fmt.Sprintf("%s/aws/%s", baseUrl, fileName)
-	log.Debugf("url=%s", sourceUrl)
-	response, err := http.Get(sourceUrl)
-	if err != nil {
-		log.Failf("an error occured attempting to download %s", sourceUrl)
-		return false
-	}
-	defer utils.CloseReadCloser(response.Body, func(s string) { log.Debug(s) })
-	configPath := fixed.GetConfigFolderPath()
-	filepath := fmt.Sprintf("%v/%v", configPath, outputPath)
-	// Create the file
-	out, err := os.Create(filepath)
-	if err != nil {
-		log.Failf("an error occured attempting to save %v \n %v", outputPath, err)
-		return false
+	if types.OutputFormat(viper.GetString(string(types.ArgOutput))) == types.OutputFormatJSON {
+		logger.CreateJsonLogger(loglevel)
+		return
 	}
-	defer utils.CloseReadCloser(out, func(s string) { log.Debug(s) })
-
-	// Write the body to file
-	_, err = io.Copy(out, response.Body)
-	log.Infof("downloaded %v", fileName)
-	return true
+	logger.CreateLogger(loglevel)
 }
 
-func createSession() provider.Session {
-	log := logger.New()
-	defer log.LogDone()
-	providerName := provider.Aws
-	session, err := provider.NewSession(providerName)
+func createSession() (provider.Session, error) {
+	providerName := provider.Provider(viper.GetString(string(types.ArgProvider)))
+	session, err := provider.NewSession(providerName, &providerTypes.SessionOptions{
+		EnvironmentName: viper.GetString(string(types.ArgEnvName)),
+		Plan:            viper.GetBool(string(types.ArgPlan)),
+		AutoRecover:     viper.GetBool(string(types.ArgAutoRecover)),
+		NamePrefix:      viper.GetString(string(types.ArgNamePrefix)),
+		Fresh:           viper.GetBool(string(types.ArgFresh)),
+	})
 	if err != nil {
-		log.Failf("Cannot start provider(%v) session ", providerName)
-		return nil
+		return nil, providerTypes.NewUserError(fmt.Sprintf("start provider(%v) session", providerName), err)
 	}
-	return session
+	return session, nil
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -138,7 +151,7 @@ func initConfig() {
 			fmt.Println("failed to read user setting entries")
 			os.Exit(logger.ExitFailureStatus)
 		}
-		viper.Set("hosted-zone-name", hostedZoneName)
+		viper.Set("hosted-zone-name", strings.TrimSpace(hostedZoneName))
 
 		fmt.Print("DomainName: ")
 		domainName, consoleReadErr := reader.ReadString('\n')
@@ -146,7 +159,7 @@ func initConfig() {
 			fmt.Println("failed to read user setting entries")
 			os.Exit(logger.ExitFailureStatus)
 		}
-		viper.Set("domain-name", domainName)
+		viper.Set("domain-name", strings.TrimSpace(domainName))
 
 		fmt.Print("DomainEmail: ")
 		domainEmail, consoleReadErr := reader.ReadString('\n')
@@ -154,7 +167,7 @@ func initConfig() {
 			fmt.Println("failed to read user setting entries")
 			os.Exit(logger.ExitFailureStatus)
 		}
-		viper.Set("domain-email", domainEmail)
+		viper.Set("domain-email", strings.TrimSpace(domainEmail))
 
 		fmt.Print("Environment name: ")
 		envName, consoleReadErr := reader.ReadString('\n')
@@ -162,7 +175,7 @@ func initConfig() {
 			fmt.Println("failed to read user setting entries")
 			os.Exit(logger.ExitFailureStatus)
 		}
-		viper.Set("env-name", envName)
+		viper.Set("env-name", strings.TrimSpace(envName))
 
 		err := viper.WriteConfigAs(cfgFilePath)
 		if err != nil {
@@ -171,9 +184,30 @@ func initConfig() {
 	}
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+		trimConfigValues()
 	} else {
-		fmt.Print(err.Error())
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+}
+
+// trimConfigValues removes the surrounding white space of the values in the config file, the setup of earlier
+// versions saved the values it asked for with their new line, e.g. an env-name of "DevTest\n"
+func trimConfigValues() {
+	fileConfig := viper.New()
+	fileConfig.SetConfigFile(viper.ConfigFileUsed())
+	if err := fileConfig.ReadInConfig(); err != nil {
+		return
+	}
+	settings := fileConfig.AllSettings()
+	for key, value := range settings {
+		if text, isText := value.(string); isText {
+			settings[key] = strings.TrimSpace(text)
+		}
+	}
+	// merged values stay below the flags and environment variables, as the values read from the file
+	if err := viper.MergeConfigMap(settings); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
 	}
 }
 
@@ -186,6 +220,12 @@ func init() {
 	rootCmd.PersistentFlags().String(string(types.ArgDomainName), "", "--domain-name <app.xyz.com>")
 	rootCmd.PersistentFlags().String(string(types.ArgDomainEmail), "", "--domain-email <ibu@xyz.com>")
 	rootCmd.PersistentFlags().String(string(types.ArgEnvName), "DevTest", "--env-name DevTest")
+	rootCmd.PersistentFlags().String(string(types.ArgOutput), string(types.OutputFormatTable), "--output [table or json - json reports every operation as a json event on stdout]")
+	rootCmd.PersistentFlags().Bool(string(types.ArgPlan), false, "--plan previews the stack changes without applying them")
+	rootCmd.PersistentFlags().Bool(string(types.ArgAutoRecover), false, "--auto-recover deletes and re-creates stacks left in a state that cannot be updated, e.g. ROLLBACK_COMPLETE, without asking")
+	rootCmd.PersistentFlags().Bool(string(types.ArgFresh), false, "--fresh ignores the steps an interrupted init, start or deploy completed and starts over")
+	rootCmd.PersistentFlags().String(string(types.ArgNamePrefix), "", "--name-prefix <prefix> is prepended to the environment name when naming stacks, clusters and repositories")
+	rootCmd.PersistentFlags().String(string(types.ArgProvider), string(provider.Aws), "--provider [aws or local - local deploys to the local docker daemon]")
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	err := viper.BindPFlags(rootCmd.PersistentFlags())
@@ -0,0 +1,265 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/docker/docker/pkg/term"
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider"
+	"github.com/kahgeh/devenv/provider/manifest"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// secretsCmd represents the secrets command
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "manage the secrets of an app",
+	Long: `manage the secrets of an app, e.g. database passwords and api keys
+they are kept encrypted in the parameter store and passed to the app containers as environment variables,
+deploy the app once its secrets change`,
+}
+
+// secretsSetCmd represents the secrets set command
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <app> KEY[=VALUE]",
+	Short: "save a secret of an app",
+	Long: `save a secret of an app
+when
+	the value is left out, it is read from stdin so that it does not end up in the shell history`,
+	RunE: setSecret,
+}
+
+// secretsGetCmd represents the secrets get command
+var secretsGetCmd = &cobra.Command{
+	Use:   "get <app> KEY",
+	Short: "show the value of a secret of an app",
+	RunE:  getSecret,
+}
+
+// secretsListCmd represents the secrets list command
+var secretsListCmd = &cobra.Command{
+	Use:   "list <app>",
+	Short: "list the secrets of an app",
+	RunE:  listSecrets,
+}
+
+// secretsRmCmd represents the secrets rm command
+var secretsRmCmd = &cobra.Command{
+	Use:   "rm <app> KEY",
+	Short: "delete a secret of an app",
+	RunE:  removeSecret,
+}
+
+// getSecretParameters reads the app and, unless listing, the key of the secret from the arguments
+func getSecretParameters(args []string, withKey bool) (*types.SecretParameters, error) {
+	if len(args) < 1 {
+		return nil, &cmdTypes.MissingArgument{ParameterName: "appName"}
+	}
+	parameters := &types.SecretParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		AppName:         args[0],
+	}
+	if !withKey {
+		return parameters, nil
+	}
+	if len(args) < 2 {
+		return nil, &cmdTypes.MissingArgument{ParameterName: "KEY"}
+	}
+	parameters.Key = args[1]
+	if index := strings.Index(args[1], "="); index >= 0 {
+		parameters.Key = args[1][:index]
+		parameters.Value = args[1][index+1:]
+	}
+	if !manifest.IsEnvironmentName(parameters.Key) {
+		return nil, types.NewUserError(fmt.Sprintf("read secret key %q", parameters.Key),
+			fmt.Errorf("a key is passed as an environment variable, it must only contain letters, digits and underscores"))
+	}
+	return parameters, nil
+}
+
+// readSecretValue reads the value from stdin, without echoing it when stdin is a terminal
+func readSecretValue(key string) (string, error) {
+	fd, isTerminal := term.GetFdInfo(os.Stdin)
+	if !isTerminal {
+		content, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(content), "\n"), "\r"), nil
+	}
+	state, err := term.SaveState(fd)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", key)
+	if err := term.DisableEcho(fd, state); err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = term.RestoreTerminal(fd, state)
+		fmt.Fprintln(os.Stderr)
+	}()
+	value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(value, "\r\n"), nil
+}
+
+func getSecretStore() (provider.SecretStore, error) {
+	session, err := createSession()
+	if err != nil {
+		return nil, err
+	}
+	store, ok := session.(provider.SecretStore)
+	if !ok {
+		return nil, types.NewUserError("manage secrets",
+			fmt.Errorf("secrets are not supported by the %s provider", viper.GetString(string(cmdTypes.ArgProvider))))
+	}
+	return store, nil
+}
+
+func setSecret(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	parameters, err := getSecretParameters(args, true)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(args[1], "=") {
+		parameters.Value, err = readSecretValue(parameters.Key)
+		if err != nil {
+			return types.NewUserError(fmt.Sprintf("read the value of %s", parameters.Key), err)
+		}
+	}
+	if parameters.Value == "" {
+		return types.NewUserError(fmt.Sprintf("save secret %s", parameters.Key), fmt.Errorf("the value is empty"))
+	}
+	store, err := getSecretStore()
+	if err != nil {
+		return err
+	}
+	return store.SetSecret(parameters)
+}
+
+func getSecret(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	parameters, err := getSecretParameters(args, true)
+	if err != nil {
+		return err
+	}
+	store, err := getSecretStore()
+	if err != nil {
+		return err
+	}
+	value, err := store.GetSecret(parameters)
+	if err != nil {
+		return err
+	}
+	log.Result("value", value)
+	log.Print(value + "\n")
+	return nil
+}
+
+func listSecrets(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	parameters, err := getSecretParameters(args, false)
+	if err != nil {
+		return err
+	}
+	store, err := getSecretStore()
+	if err != nil {
+		return err
+	}
+	keys, err := store.ListSecrets(parameters)
+	if err != nil {
+		return err
+	}
+	log.Result("keys", keys)
+	if len(keys) == 0 {
+		log.Print(fmt.Sprintf("%s has no secrets\n", parameters.AppName))
+		return nil
+	}
+	log.Print(strings.Join(keys, "\n") + "\n")
+	return nil
+}
+
+func removeSecret(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	parameters, err := getSecretParameters(args, true)
+	if err != nil {
+		return err
+	}
+	store, err := getSecretStore()
+	if err != nil {
+		return err
+	}
+	return store.RemoveSecret(parameters)
+}
+
+func init() {
+	rootCmd.AddCommand(secretsCmd)
+	secretsCmd.AddCommand(secretsSetCmd)
+	secretsCmd.AddCommand(secretsGetCmd)
+	secretsCmd.AddCommand(secretsListCmd)
+	secretsCmd.AddCommand(secretsRmCmd)
+}
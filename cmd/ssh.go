@@ -0,0 +1,80 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const argWriteConfig cmdTypes.ArgName = "write-config"
+
+// sshCmd represents the ssh command
+var sshCmd = &cobra.Command{
+	Use:   "ssh [-- command]",
+	Short: "ssh into the environment instance",
+	Long: `ssh into the instance the public ip is attached to, using the key pair created by init
+	- known hosts are kept apart from ~/.ssh/known_hosts and reset when the instance changes
+	- a command after -- runs on the instance instead of opening a shell, e.g. devenv ssh -- docker ps
+when
+	write-config is set, a Host devenv-<env-name> entry is written to ~/.ssh/config instead of connecting`,
+	RunE: ssh,
+}
+
+func ssh(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	secureShell, ok := session.(provider.SecureShell)
+	if !ok {
+		return types.NewUserError("ssh into the environment",
+			fmt.Errorf("ssh is not supported by the %s provider", viper.GetString(string(cmdTypes.ArgProvider))))
+	}
+	return secureShell.Ssh(&types.SshParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		Command:         args,
+		WriteConfig:     viper.GetBool(string(argWriteConfig)),
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(sshCmd)
+	sshCmd.PersistentFlags().Bool(string(argWriteConfig), false, "--write-config adds a Host devenv-<env-name> entry to ~/.ssh/config")
+	err := viper.BindPFlags(sshCmd.PersistentFlags())
+	if err != nil {
+		fmt.Printf("fail to bind command arguments\n %s", err.Error())
+		os.Exit(logger.ExitFailureStatus)
+	}
+}
@@ -29,42 +29,59 @@ import (
 // startCmd represents the start command
 var startCmd = &cobra.Command{
 	Use:   "start",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: start,
+	Short: "start the environment instance and attach the public ip",
+	Long: `start the environment instance and attach the public ip
+when
+	ttl or stop-at is set, the instance is shut down and the public ip released at the deadline, see devenv ttl`,
+	RunE: start,
 }
 
-func mapParameters(_ []string) *types.StartParameters {
+func mapParameters(_ []string) (*types.StartParameters, error) {
+	stopAt, err := getDeadline(viper.GetDuration(string(argTtl)), viper.GetString(string(argStopAt)))
+	if err != nil {
+		return nil, err
+	}
+	fleet, err := getFleetParameters()
+	if err != nil {
+		return nil, err
+	}
 	return &types.StartParameters{
 		HostedZoneName: viper.GetString("hosted-zone-name"),
 		DomainName:  viper.GetString("domain-name"),
 		EnvironmentName: viper.GetString("env-name"),
-	}
+		StopAt: stopAt,
+		Fleet: fleet,
+	}, nil
 }
 
-func start(_ *cobra.Command, args []string) {
+func start(_ *cobra.Command, args []string) (err error) {
 	startLog()
 	log := logger.New()
 	defer log.LogDone()
 	defer func() {
-		if err := recover(); err != nil {
-			log.Failf("%v", err)
-			log.Debugf("stacktrace : \n %v" + string(debug.Stack()))
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
 		}
 	}()
-	parameters := mapParameters(args)
-	createSession().Start(parameters)
+	parameters, err := mapParameters(args)
+	if err != nil {
+		return err
+	}
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	return session.Start(parameters)
 }
 
 func init() {
 	rootCmd.AddCommand(startCmd)
 	startCmd.PersistentFlags().String("hosted-zone-name", "", "--hosted-zone-name xyz.com. , remember to include the period at the end")
-	err := viper.BindPFlags(deployCmd.PersistentFlags())
+	startCmd.PersistentFlags().Duration(string(argTtl), 0, "--ttl 4h shuts the environment down after 4 hours")
+	startCmd.PersistentFlags().String(string(argStopAt), "", "--stop-at 19:00 shuts the environment down at the next 19:00 local time")
+	startCmd.PersistentFlags().AddFlagSet(fleetFlags)
+	err := viper.BindPFlags(startCmd.PersistentFlags())
 	if err != nil {
 		fmt.Printf("fail to bind command arguments\n %s", err.Error())
 		os.Exit(logger.ExitFailureStatus)
@@ -0,0 +1,102 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+	"text/tabwriter"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show the state of the environment",
+	Long: `show the state of the environment
+	- vpc, ecs cluster, spot fleet and public ip stacks
+	- spot fleet instances
+	- public ip association and the dns record created for it
+	- apps and their running and desired counts`,
+	RunE: status,
+}
+
+func printStatusTable(writer io.Writer, environmentStatus *types.EnvironmentStatus) {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(table, "ENVIRONMENT\t%s\n\n", environmentStatus.EnvironmentName)
+
+	_, _ = fmt.Fprintln(table, "STACK\tNAME\tSTATUS")
+	for _, stack := range environmentStatus.Stacks {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\n", stack.Role, stack.Name, stack.Status)
+	}
+
+	_, _ = fmt.Fprintln(table, "\nINSTANCE\tTYPE\tSTATE")
+	for _, instance := range environmentStatus.Instances {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\n", instance.InstanceID, instance.InstanceType, instance.State)
+	}
+
+	if publicIP := environmentStatus.PublicIP; publicIP != nil {
+		_, _ = fmt.Fprintln(table, "\nPUBLIC IP\tINSTANCE\tDNS RECORD")
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\n", publicIP.PublicIP, publicIP.InstanceID, publicIP.DomainName)
+	}
+
+	_, _ = fmt.Fprintln(table, "\nAPP\tSTACK\tSTATUS\tRUNNING\tDESIRED")
+	for _, app := range environmentStatus.Apps {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%v\t%v\n", app.Name, app.StackName, app.StackStatus, app.RunningCount, app.DesiredCount)
+	}
+	_ = table.Flush()
+}
+
+func status(_ *cobra.Command, _ []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	environmentStatus, err := session.Status(&types.StatusParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmdTypes.OutputFormat(viper.GetString(string(cmdTypes.ArgOutput))) == cmdTypes.OutputFormatJSON {
+		log.Result("status", environmentStatus)
+		return nil
+	}
+	printStatusTable(os.Stdout, environmentStatus)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"runtime/debug"
 
 	"github.com/kahgeh/devenv/logger"
@@ -27,21 +28,25 @@ var stopCmd = &cobra.Command{
 	Use:   "stop",
 	Short: "remove instance and attached resource",
 	Long:  `remove instance and attached resource`,
-	Run:   stop,
+	RunE:  stop,
 }
 
-func stop(_ *cobra.Command, _ []string) {
+func stop(_ *cobra.Command, _ []string) (err error) {
 	startLog()
 	log := logger.New()
 	defer log.LogDone()
 	defer func() {
-		if err := recover(); err != nil {
-			log.Failf("%v", err)
-			log.Debugf("stacktrace : \n %v" + string(debug.Stack()))
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
 		}
 	}()
 
-	createSession().Stop()
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	return session.Stop()
 }
 func init() {
 	rootCmd.AddCommand(stopCmd)
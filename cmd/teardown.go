@@ -16,6 +16,7 @@ limitations under the License.
 package cmd
 
 import (
+	"fmt"
 	"runtime/debug"
 
 	"github.com/kahgeh/devenv/logger"
@@ -27,19 +28,23 @@ var teardownCmd = &cobra.Command{
 	Use:   "teardown",
 	Short: "Tear down the environment",
 	Long:  `Tear down the environment.`,
-	Run:   tearDown,
+	RunE:  tearDown,
 }
 
-func tearDown(_ *cobra.Command, _ []string) {
+func tearDown(_ *cobra.Command, _ []string) (err error) {
 	startLog()
 	log := logger.New()
 	defer func() {
-		if err := recover(); err != nil {
-			log.Debug(err)
-			log.Debugf("stacktrace : \n %v" + string(debug.Stack()))
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
 		}
 	}()
-	createSession().Delete()
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	return session.Delete()
 }
 
 func init() {
@@ -0,0 +1,121 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	templates "github.com/kahgeh/devenv/aws"
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/fixed"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	argFolder    cmdTypes.ArgName = "folder"
+	argOverwrite cmdTypes.ArgName = "overwrite"
+)
+
+// templatesCmd represents the templates command
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "manage the built in templates",
+	Long: `manage the built in cloudformation templates and front proxy assets,
+files in ~/.devenv/aws are used instead of the built in ones with the same name`,
+}
+
+// templatesExportCmd represents the templates export command
+var templatesExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "write the built in templates to disk for customisation",
+	Long: `write the built in templates to disk for customisation, by default to ~/.devenv/aws where they override the built in ones
+	- existing files are kept unless overwrite is set`,
+	RunE: exportTemplates,
+}
+
+func exportTemplateFiles(folderPath string, overwrite bool) (written []string, err error) {
+	err = fs.WalkDir(templates.Files, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(folderPath, filepath.FromSlash(name))
+		if entry.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+		if _, err := os.Stat(targetPath); err == nil && !overwrite {
+			return nil
+		}
+		content, err := fs.ReadFile(templates.Files, name)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(targetPath, content, 0644); err != nil {
+			return err
+		}
+		written = append(written, targetPath)
+		return nil
+	})
+	return
+}
+
+func exportTemplates(_ *cobra.Command, _ []string) (err error) {
+	startLog()
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	folderPath := viper.GetString(string(argFolder))
+	if folderPath == "" {
+		folderPath = fmt.Sprintf("%s/aws", fixed.GetConfigFolderPath())
+	}
+	written, err := exportTemplateFiles(folderPath, viper.GetBool(string(argOverwrite)))
+	if err != nil {
+		err = types.NewError(fmt.Sprintf("export templates to %s", folderPath), err)
+		log.Fail(err)
+		return err
+	}
+	for _, filePath := range written {
+		log.Print(fmt.Sprintf("%s\n", filePath))
+	}
+	log.Result("files", written)
+	log.Succeedf("Exported %v files to %s", len(written), folderPath)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(templatesCmd)
+	templatesCmd.AddCommand(templatesExportCmd)
+	templatesExportCmd.PersistentFlags().String(string(argFolder), "", "--folder <path> [default is ~/.devenv/aws]")
+	templatesExportCmd.PersistentFlags().Bool(string(argOverwrite), false, "--overwrite replaces files that were exported before")
+	err := viper.BindPFlags(templatesExportCmd.PersistentFlags())
+	if err != nil {
+		fmt.Printf("fail to bind command arguments\n %s", err.Error())
+		os.Exit(logger.ExitFailureStatus)
+	}
+}
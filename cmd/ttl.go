@@ -0,0 +1,202 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	argTtl    cmdTypes.ArgName = "ttl"
+	argStopAt cmdTypes.ArgName = "stop-at"
+)
+
+// ttlCmd represents the ttl command
+var ttlCmd = &cobra.Command{
+	Use:   "ttl",
+	Short: "manage the scheduled shutdown of the environment",
+	Long: `manage the scheduled shutdown of the environment, start schedules it with --ttl or --stop-at
+at the deadline the spot fleet target capacity is set to 0 and the public ip is released`,
+}
+
+// ttlShowCmd represents the ttl show command
+var ttlShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "show when the environment shuts down",
+	RunE:  showTtl,
+}
+
+// ttlExtendCmd represents the ttl extend command
+var ttlExtendCmd = &cobra.Command{
+	Use:   "extend <duration or time>",
+	Short: "postpone the shutdown of the environment",
+	Long: `postpone the shutdown of the environment
+	- a duration, e.g. 2h, is added to the current deadline
+	- a time, e.g. 21:00, replaces it with the next 21:00 local time`,
+	RunE: extendTtl,
+}
+
+// parseStopAt returns the next occurrence of the local time of day, e.g. 19:00
+func parseStopAt(stopAt string, now time.Time) (time.Time, error) {
+	clock, err := time.ParseInLocation("15:04", stopAt, now.Location())
+	if err != nil {
+		return time.Time{}, types.NewUserError(fmt.Sprintf("read stop time %q", stopAt),
+			fmt.Errorf("expected a time of day such as 19:00"))
+	}
+	deadline := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !deadline.After(now) {
+		deadline = deadline.AddDate(0, 0, 1)
+	}
+	return deadline, nil
+}
+
+// getDeadline returns the deadline of either the ttl or the stop time, nil when neither is set
+func getDeadline(ttl time.Duration, stopAt string) (*time.Time, error) {
+	if ttl != 0 && stopAt != "" {
+		return nil, types.NewUserError("schedule the shutdown", fmt.Errorf("use either --ttl or --stop-at"))
+	}
+	now := time.Now()
+	if ttl < 0 {
+		return nil, types.NewUserError("schedule the shutdown", fmt.Errorf("--ttl must be positive"))
+	}
+	if ttl > 0 {
+		deadline := now.Add(ttl)
+		return &deadline, nil
+	}
+	if stopAt == "" {
+		return nil, nil
+	}
+	deadline, err := parseStopAt(stopAt, now)
+	if err != nil {
+		return nil, err
+	}
+	return &deadline, nil
+}
+
+func describeDeadline(deadline time.Time) string {
+	remaining := time.Until(deadline).Round(time.Minute)
+	if remaining < 0 {
+		return fmt.Sprintf("%s, passed", deadline.Local().Format("Mon 2 Jan 15:04 MST"))
+	}
+	return fmt.Sprintf("%s, in %v", deadline.Local().Format("Mon 2 Jan 15:04 MST"), remaining)
+}
+
+func getShutdownScheduler() (provider.ShutdownScheduler, error) {
+	session, err := createSession()
+	if err != nil {
+		return nil, err
+	}
+	scheduler, ok := session.(provider.ShutdownScheduler)
+	if !ok {
+		return nil, types.NewUserError("manage the shutdown",
+			fmt.Errorf("scheduled shutdowns are not supported by the %s provider", viper.GetString(string(cmdTypes.ArgProvider))))
+	}
+	return scheduler, nil
+}
+
+func showTtl(_ *cobra.Command, _ []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	scheduler, err := getShutdownScheduler()
+	if err != nil {
+		return err
+	}
+	schedule, err := scheduler.GetShutdownSchedule()
+	if err != nil {
+		return err
+	}
+	schedule.EnvironmentName = viper.GetString(string(cmdTypes.ArgEnvName))
+	log.Result("shutdown", schedule)
+	if schedule.Deadline == nil {
+		log.Print("no shutdown scheduled\n")
+		return nil
+	}
+	if schedule.Passed {
+		log.Print(fmt.Sprintf("no shutdown scheduled, the last one was at %s\n",
+			schedule.Deadline.Local().Format("Mon 2 Jan 15:04 MST")))
+		return nil
+	}
+	log.Print(fmt.Sprintf("shutdown at %s\n", describeDeadline(*schedule.Deadline)))
+	return nil
+}
+
+func extendTtl(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	if len(args) < 1 {
+		return &cmdTypes.MissingArgument{ParameterName: "duration or time"}
+	}
+	parameters := &types.ExtendShutdownParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+	}
+	if strings.Contains(args[0], ":") {
+		stopAt, err := parseStopAt(args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		parameters.StopAt = &stopAt
+	} else {
+		parameters.By, err = time.ParseDuration(args[0])
+		if err != nil || parameters.By <= 0 {
+			return types.NewUserError(fmt.Sprintf("read duration %q", args[0]), fmt.Errorf("expected a positive duration such as 2h"))
+		}
+	}
+
+	scheduler, err := getShutdownScheduler()
+	if err != nil {
+		return err
+	}
+	schedule, err := scheduler.ExtendShutdown(parameters)
+	if err != nil {
+		return err
+	}
+	schedule.EnvironmentName = parameters.EnvironmentName
+	log.Result("shutdown", schedule)
+	log.Print(fmt.Sprintf("shutdown at %s\n", describeDeadline(*schedule.Deadline)))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(ttlCmd)
+	ttlCmd.AddCommand(ttlShowCmd)
+	ttlCmd.AddCommand(ttlExtendCmd)
+}
@@ -1,6 +1,10 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/kahgeh/devenv/provider/types"
+)
 
 type ArgName string
 
@@ -8,6 +12,19 @@ const (
 	ArgEnvName     ArgName = "env-name"
 	ArgDomainName  ArgName = "domain-name"
 	ArgDomainEmail ArgName = "domain-email"
+	ArgProvider    ArgName = "provider"
+	ArgOutput      ArgName = "output"
+	ArgAutoRecover ArgName = "auto-recover"
+	ArgNamePrefix  ArgName = "name-prefix"
+	ArgPlan        ArgName = "plan"
+	ArgFresh       ArgName = "fresh"
+)
+
+type OutputFormat string
+
+const (
+	OutputFormatTable OutputFormat = "table"
+	OutputFormatJSON  OutputFormat = "json"
 )
 
 type KnownApp string
@@ -23,3 +40,8 @@ type MissingArgument struct {
 func (e *MissingArgument) Error() string {
 	return fmt.Sprintf("%q is required", e.ParameterName)
 }
+
+// Kind classifies a missing argument as a mistake of the user
+func (e *MissingArgument) Kind() types.ErrorKind {
+	return types.UserErrorKind
+}
@@ -0,0 +1,78 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const argPurgeImages cmdTypes.ArgName = "purge-images"
+
+// undeployCmd represents the undeploy command
+var undeployCmd = &cobra.Command{
+	Use:   "undeploy <name>",
+	Short: "remove a deployed service",
+	Long: `remove a deployed service
+	- deletes the app stack
+	- deletes the parameters under /allEnvs/<env-name>/apps/<name>
+when
+	purge-images is set, the images are deleted and then the repository stack`,
+	RunE: undeploy,
+}
+
+func undeploy(_ *cobra.Command, args []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	if len(args) < 1 {
+		return &cmdTypes.MissingArgument{ParameterName: "appName"}
+	}
+
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	return session.Undeploy(&types.UndeployParameters{
+		AppName:         args[0],
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		PurgeImages:     viper.GetBool(string(argPurgeImages)),
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(undeployCmd)
+	undeployCmd.PersistentFlags().Bool(string(argPurgeImages), false, "--purge-images also deletes the images and the repository of the app")
+	err := viper.BindPFlags(undeployCmd.PersistentFlags())
+	if err != nil {
+		fmt.Printf("fail to bind command arguments\n %s", err.Error())
+		os.Exit(logger.ExitFailureStatus)
+	}
+}
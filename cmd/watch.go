@@ -0,0 +1,96 @@
+/*
+Copyright © 2020 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+	"time"
+
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	argPollInterval cmdTypes.ArgName = "poll-interval"
+	argDeployRule   cmdTypes.ArgName = "deploy-rule"
+	argRemoveRule   cmdTypes.ArgName = "remove-rule"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "reattach the public ip when the spot fleet replaces an interrupted instance",
+	Long: `reattach the public ip when the spot fleet replaces an interrupted instance,
+the spot fleet changes, e.g. interruptions and launches, are reported till interrupted
+when
+	deploy-rule is set, a rule that reattaches the public ip without a running watch is deployed instead
+	remove-rule is set, the rule is removed`,
+	RunE: watch,
+}
+
+func watch(_ *cobra.Command, _ []string) (err error) {
+	startLog()
+	log := logger.New()
+	defer log.LogDone()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+			log.Debugf("stacktrace : \n %v", string(debug.Stack()))
+		}
+	}()
+
+	parameters := &types.WatchParameters{
+		EnvironmentName: viper.GetString(string(cmdTypes.ArgEnvName)),
+		Interval:        viper.GetDuration(string(argPollInterval)),
+		DeployRule:      viper.GetBool(string(argDeployRule)),
+		RemoveRule:      viper.GetBool(string(argRemoveRule)),
+	}
+	if parameters.DeployRule && parameters.RemoveRule {
+		return types.NewUserError("watch", fmt.Errorf("use either --deploy-rule or --remove-rule"))
+	}
+	if parameters.Interval < time.Second {
+		return types.NewUserError("watch", fmt.Errorf("--poll-interval must be at least 1s"))
+	}
+
+	session, err := createSession()
+	if err != nil {
+		return err
+	}
+	watcher, ok := session.(provider.Watcher)
+	if !ok {
+		return types.NewUserError("watch",
+			fmt.Errorf("watch is not supported by the %s provider", viper.GetString(string(cmdTypes.ArgProvider))))
+	}
+	return watcher.Watch(parameters)
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	watchCmd.PersistentFlags().Duration(string(argPollInterval), 30*time.Second, "--poll-interval 1m checks the public ip every minute")
+	watchCmd.PersistentFlags().Bool(string(argDeployRule), false, "--deploy-rule deploys a rule that reattaches the public ip without a running watch")
+	watchCmd.PersistentFlags().Bool(string(argRemoveRule), false, "--remove-rule removes the rule deployed by --deploy-rule")
+	err := viper.BindPFlags(watchCmd.PersistentFlags())
+	if err != nil {
+		fmt.Printf("fail to bind command arguments\n %s", err.Error())
+		os.Exit(logger.ExitFailureStatus)
+	}
+}
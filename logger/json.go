@@ -0,0 +1,80 @@
+package logger
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	StartedEvent   = "started"
+	ProgressEvent  = "progress"
+	OutputEvent    = "output"
+	DebugEvent     = "debug"
+	SucceededEvent = "succeeded"
+	SkippedEvent   = "skipped"
+	FailedEvent    = "failed"
+	ResultEvent    = "result"
+)
+
+type jsonEvent struct {
+	Time    string                 `json:"time"`
+	Event   string                 `json:"event"`
+	Task    string                 `json:"task,omitempty"`
+	Message string                 `json:"message,omitempty"`
+	Error   string                 `json:"error,omitempty"`
+	Result  map[string]interface{} `json:"result,omitempty"`
+}
+
+// JsonSink writes one json event per line so that scripts can follow the operations,
+// results are collected and written as a single result event at the end
+type JsonSink struct {
+	mutex   sync.Mutex
+	writer  io.Writer
+	results map[string]interface{}
+	// err is why the command failed, reported with the results
+	err     string
+	flushed bool
+}
+
+func newJsonSink() *JsonSink {
+	return &JsonSink{
+		writer:  os.Stdout,
+		results: map[string]interface{}{},
+	}
+}
+
+func (sink *JsonSink) emit(event jsonEvent) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	_ = json.NewEncoder(sink.writer).Encode(event)
+}
+
+func (sink *JsonSink) record(key string, value interface{}) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.results[key] = value
+}
+
+func (sink *JsonSink) fail(err error) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	sink.err = err.Error()
+}
+
+// flush writes the result event, only the first call has an effect
+func (sink *JsonSink) flush() {
+	sink.mutex.Lock()
+	if sink.flushed {
+		sink.mutex.Unlock()
+		return
+	}
+	sink.flushed = true
+	results := sink.results
+	err := sink.err
+	sink.mutex.Unlock()
+	sink.emit(jsonEvent{Event: ResultEvent, Result: results, Error: err})
+}
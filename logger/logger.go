@@ -1,14 +1,15 @@
 package logger
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"runtime"
 	"strings"
-	"time"
+	"sync"
 
+	"github.com/docker/docker/pkg/term"
 	"github.com/kahgeh/devenv/lang"
-	"github.com/theckman/yacspin"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -25,10 +26,13 @@ const ExitFailureStatus = 3
 
 type loggerState struct {
 	defaultLogger      *Spinner
+	jsonLogger         *JsonSink
 	detailedLoggerBase *zap.Logger
 	detailedLogger     *zap.SugaredLogger
-	names              []string
 	level              LogLevel
+	// mutex guards scopes, tasks that do not depend on each other log concurrently
+	mutex  sync.Mutex
+	scopes []*scope
 }
 
 var state *loggerState
@@ -67,28 +71,9 @@ func newConsoleConfig(level zapcore.Level, callerKey string) zap.Config {
 	}
 }
 
-func NewSpinner() *yacspin.Spinner {
-	cfg := yacspin.Config{
-		Frequency:         100 * time.Millisecond,
-		CharSet:           yacspin.CharSets[37],
-		Suffix:            "",
-		SuffixAutoColon:   true,
-		StopCharacter:     "✓",
-		StopFailCharacter: "✗",
-		StopColors:        []string{"fgGreen"},
-		StopFailColors:    []string{"fgRed"},
-	}
-
-	spinner, _ := yacspin.New(cfg)
-	return spinner
-}
-
 func GetCallerFunctionName() string {
 	// Skip GetCallerFunctionName and the function to get the caller of
-	fullFuncName := getFrame(2).Function
-	parts := strings.Split(fullFuncName, "/")
-	parts = strings.Split(parts[len(parts)-1], ".")
-	return parts[len(parts)-1]
+	return shortFunctionName(getFrame(2).Function)
 }
 
 func getFrame(skipFrames int) runtime.Frame {
@@ -135,10 +120,7 @@ func CreateLogger(level LogLevel) {
 	}
 
 	if level == NormalLogLevel {
-		spinner := &Spinner{
-			logStream:    make(chan string),
-			newComponent: make(chan *yacspin.Spinner),
-		}
+		spinner := newSpinner()
 		state = &loggerState{
 			level:              level,
 			defaultLogger:      spinner,
@@ -150,20 +132,98 @@ func CreateLogger(level LogLevel) {
 	}
 }
 
+// CreateJsonLogger reports operations as json events on stdout instead of spinners or console logs,
+// debug events are only included at the debug log level
+func CreateJsonLogger(level LogLevel) {
+	state = &loggerState{
+		level:      level,
+		jsonLogger: newJsonSink(),
+	}
+}
+
 func Sync() {
+	if state == nil {
+		return
+	}
+	if state.jsonLogger != nil {
+		state.jsonLogger.flush()
+		return
+	}
 	if state.level > NormalLogLevel {
 		state.detailedLoggerBase.Sync()
 		state.detailedLogger.Sync()
 	}
 }
 
+// ReportError tells why the command failed once every task has completed,
+// as part of the result event with the json output and on stderr otherwise
+func ReportError(err error) {
+	if state != nil && state.jsonLogger != nil {
+		state.jsonLogger.fail(err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+}
+
+// CanPrompt tells whether the user can answer questions, json output and input that is not a terminal are for scripts
+func CanPrompt() bool {
+	if state != nil && state.jsonLogger != nil {
+		return false
+	}
+	_, isTerminal := term.GetFdInfo(os.Stdin)
+	return isTerminal
+}
+
+// Confirm asks a yes or no question on stderr, running spinners are paused till it is answered
+func Confirm(question string) (bool, error) {
+	readAnswer := func() (string, error) {
+		return bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	question = fmt.Sprintf("%s [y/N] ", question)
+	var answer string
+	var err error
+	if state != nil && state.defaultLogger != nil {
+		answer, err = state.defaultLogger.ask(question, readAnswer)
+	} else {
+		fmt.Fprint(os.Stderr, question)
+		answer, err = readAnswer()
+	}
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
 type Logger struct {
-	defaultLogger  *Spinner
+	defaultLogger *Spinner
+	// line is the spinner line of the task the logger reports on
+	line           *spinnerLine
 	detailedLogger *zap.SugaredLogger
+	jsonLogger     *JsonSink
+	// task is the dotted path of the operation, reported by the json events
+	task           string
+	successMessage string
 	level          LogLevel
 	LogDone        func()
 }
 
+func newJsonLogger(current *scope, successMessage string) *Logger {
+	return &Logger{
+		jsonLogger:     state.jsonLogger,
+		task:           strings.Join(current.names, "."),
+		successMessage: successMessage,
+		level:          state.level,
+		LogDone: func() {
+			closeScope(current)
+		},
+	}
+}
+
+func (logger *Logger) emit(event string, message string) {
+	logger.jsonLogger.emit(jsonEvent{Event: event, Task: logger.task, Message: message})
+}
+
 func toLower(words []string) []string {
 	var lowerCasedWords []string
 	for _, word := range words {
@@ -172,11 +232,11 @@ func toLower(words []string) []string {
 	return lowerCasedWords
 }
 
-func getName() string {
-	fullName := strings.Join(state.names, ".")
-	if len(fullName) > 20 && len(state.names) > 1 {
-		lastName := state.names[len(state.names)-1]
-		placeHolders := strings.Repeat(".", len(state.names)-1)
+func getName(names []string) string {
+	fullName := strings.Join(names, ".")
+	if len(fullName) > 20 && len(names) > 1 {
+		lastName := names[len(names)-1]
+		placeHolders := strings.Repeat(".", len(names)-1)
 		fullName = fmt.Sprintf("%s%s", placeHolders, lastName)
 	}
 	padding := ""
@@ -187,28 +247,31 @@ func getName() string {
 	return fmt.Sprintf("[%s]%s", fullName, padding)
 }
 
+// NewTaskLogger reports the calling function as a task, with its own spinner line
 func NewTaskLogger() *Logger {
-	funcName := GetCallerFunctionName()
+	current := openScope(1)
+	funcName := shortFunctionName(current.function())
 	words := toLower(lang.ToSentence(funcName))
 	opName := lang.ToPresentParticiple(words)
-	state.names = append(state.names, funcName)
+	if state.jsonLogger != nil {
+		logger := newJsonLogger(current, fmt.Sprintf("Successfully %s", lang.ToPastTensePhrase(words)))
+		logger.emit(StartedEvent, opName)
+		return logger
+	}
 	if state.level == NormalLogLevel {
-		spinnerComponent := NewSpinner()
 		failMessage := fmt.Sprintf("Failed to %s", strings.Join(words, " "))
 		successMessage := fmt.Sprintf("Successfully %s", lang.ToPastTensePhrase(words))
-		spinnerComponent.Message(opName)
-		spinnerComponent.StopMessage(successMessage)
-		spinnerComponent.StopFailMessage(failMessage)
-		state.defaultLogger.newComponent <- spinnerComponent
+		current.line = state.defaultLogger.start(opName, successMessage, failMessage)
 		return &Logger{
 			defaultLogger: state.defaultLogger,
+			line:          current.line,
 			LogDone: func() {
-				removeFuncName()
+				closeScope(current)
 			},
 		}
 	}
 
-	detailedLogger := state.detailedLogger.Named(getName())
+	detailedLogger := state.detailedLogger.Named(getName(current.names))
 	if state.level == DebugLogLevel {
 		detailedLogger.Debug(opName)
 	}
@@ -219,25 +282,30 @@ func NewTaskLogger() *Logger {
 			if state.level == DebugLogLevel {
 				detailedLogger.Debugf("done %s", opName)
 			}
-			removeFuncName()
+			closeScope(current)
 		},
 	}
 }
 
+// New reports on the task of the calling function, if any
 func New() *Logger {
-	funcName := GetCallerFunctionName()
+	current := openScope(1)
+	funcName := shortFunctionName(current.function())
 	words := lang.ToSentence(funcName)
 	opName := lang.ToPresentParticiple(words)
-	state.names = append(state.names, funcName)
+	if state.jsonLogger != nil {
+		return newJsonLogger(current, "")
+	}
 	if state.level == NormalLogLevel {
 		return &Logger{
 			defaultLogger: state.defaultLogger,
+			line:          current.line,
 			LogDone: func() {
-				removeFuncName()
+				closeScope(current)
 			},
 		}
 	}
-	detailedLogger := state.detailedLogger.Named(getName())
+	detailedLogger := state.detailedLogger.Named(getName(current.names))
 	if state.level == DebugLogLevel {
 		detailedLogger.Debugf("%s", opName)
 	}
@@ -249,35 +317,69 @@ func New() *Logger {
 			if state.level == DebugLogLevel {
 				detailedLogger.Debugf("done %s", opName)
 			}
-			removeFuncName()
+			closeScope(current)
 		},
 	}
 }
 
-func removeFuncName() {
-	if len(state.names) > 0 {
-		state.names = state.names[:len(state.names)-1]
-	}
-}
-
 func (logger *Logger) Infof(template string, args ...interface{}) {
+	if logger.jsonLogger != nil {
+		logger.emit(ProgressEvent, fmt.Sprintf(template, args...))
+		return
+	}
 	if logger.defaultLogger != nil {
-		logger.defaultLogger.update(fmt.Sprintf(template, args...))
+		logger.defaultLogger.update(logger.line, fmt.Sprintf(template, args...))
 		return
 	}
 	logger.detailedLogger.Infof(template, args...)
 }
 
 func (logger *Logger) Info(args ...interface{}) {
+	if logger.jsonLogger != nil {
+		logger.emit(ProgressEvent, fmt.Sprint(args...))
+		return
+	}
 	if logger.defaultLogger != nil {
-		logger.defaultLogger.update(fmt.Sprintf("%v", args...))
+		logger.defaultLogger.update(logger.line, fmt.Sprint(args...))
 		return
 	}
 
 	logger.detailedLogger.Info(args...)
 }
 
+// Progressf reports the progress of a long running operation
+func (logger *Logger) Progressf(template string, args ...interface{}) {
+	if logger.jsonLogger != nil {
+		logger.emit(ProgressEvent, fmt.Sprintf(template, args...))
+		return
+	}
+	if logger.defaultLogger != nil {
+		logger.defaultLogger.progress(logger.line, fmt.Sprintf(template, args...))
+		return
+	}
+	logger.detailedLogger.Infof(template, args...)
+}
+
+// Print writes output meant for the user, e.g. a report, regardless of the log level
+func (logger *Logger) Print(text string) {
+	if logger.jsonLogger != nil {
+		logger.emit(OutputEvent, strings.Trim(text, "\n"))
+		return
+	}
+	if logger.defaultLogger != nil {
+		logger.defaultLogger.print(text)
+		return
+	}
+	fmt.Print(text)
+}
+
 func (logger *Logger) Debugf(template string, args ...interface{}) {
+	if logger.jsonLogger != nil {
+		if logger.level == DebugLogLevel {
+			logger.emit(DebugEvent, fmt.Sprintf(template, args...))
+		}
+		return
+	}
 	if logger.defaultLogger != nil {
 		return
 	}
@@ -285,6 +387,12 @@ func (logger *Logger) Debugf(template string, args ...interface{}) {
 }
 
 func (logger *Logger) Debug(args ...interface{}) {
+	if logger.jsonLogger != nil {
+		if logger.level == DebugLogLevel {
+			logger.emit(DebugEvent, fmt.Sprint(args...))
+		}
+		return
+	}
 	if logger.defaultLogger != nil {
 		return
 	}
@@ -293,6 +401,10 @@ func (logger *Logger) Debug(args ...interface{}) {
 }
 
 func (logger *Logger) DebugFunc(log func(), mustExecute func()) {
+	if logger.jsonLogger != nil {
+		mustExecute()
+		return
+	}
 
 	if logger.defaultLogger != nil {
 		mustExecute()
@@ -302,39 +414,74 @@ func (logger *Logger) DebugFunc(log func(), mustExecute func()) {
 	log()
 }
 
+// Fail reports the task as failed, the caller is expected to return the error that caused it
 func (logger *Logger) Fail(args ...interface{}) {
-	if state.defaultLogger != nil {
-		state.defaultLogger.failed(fmt.Sprintf("%v", args...))
-		os.Exit(ExitFailureStatus)
+	if logger.jsonLogger != nil {
+		logger.jsonLogger.emit(jsonEvent{Event: FailedEvent, Task: logger.task, Error: fmt.Sprint(args...)})
+		return
+	}
+	if logger.defaultLogger != nil {
+		logger.defaultLogger.failed(logger.line)
 		return
 	}
 	logger.detailedLogger.Error(args...)
-	os.Exit(ExitFailureStatus)
 }
 
+// Failf reports the task as failed, the caller is expected to return the error that caused it
 func (logger *Logger) Failf(template string, args ...interface{}) {
-	if state.defaultLogger != nil {
-		state.defaultLogger.failed(fmt.Sprintf(template, args...))
-		os.Exit(ExitFailureStatus)
+	if logger.jsonLogger != nil {
+		logger.jsonLogger.emit(jsonEvent{Event: FailedEvent, Task: logger.task, Error: fmt.Sprintf(template, args...)})
+		return
+	}
+	if logger.defaultLogger != nil {
+		logger.defaultLogger.failed(logger.line)
+		return
+	}
+	logger.detailedLogger.Errorf(template, args...)
+}
+
+// Skipf completes the task without doing it, the message explains why
+func (logger *Logger) Skipf(template string, args ...interface{}) {
+	if logger.jsonLogger != nil {
+		logger.emit(SkippedEvent, fmt.Sprintf(template, args...))
+		return
+	}
+	if logger.defaultLogger != nil {
+		logger.defaultLogger.skipped(logger.line, fmt.Sprintf(template, args...))
 		return
 	}
-	logger.detailedLogger.Errorf(template, args)
-	os.Exit(ExitFailureStatus)
+
+	logger.detailedLogger.Infof(template, args...)
 }
 
 func (logger *Logger) Succeed() {
+	if logger.jsonLogger != nil {
+		logger.emit(SucceededEvent, logger.successMessage)
+		return
+	}
 	if logger.defaultLogger != nil {
-		state.defaultLogger.succeed()
+		logger.defaultLogger.succeed(logger.line, "")
 	}
 }
 
 func (logger *Logger) Succeedf(template string, args ...interface{}) {
+	if logger.jsonLogger != nil {
+		logger.emit(SucceededEvent, fmt.Sprintf(template, args...))
+		return
+	}
 	if logger.defaultLogger != nil {
-		message := fmt.Sprintf(template, args...)
-		state.defaultLogger.update(message)
-		state.defaultLogger.succeed()
+		logger.defaultLogger.succeed(logger.line, fmt.Sprintf(template, args...))
 		return
 	}
 
 	logger.detailedLogger.Infof(template, args...)
 }
+
+// Result records a value scripts may need, e.g. the deployed image, it is only reported by the json output
+func (logger *Logger) Result(key string, value interface{}) {
+	if logger.jsonLogger != nil {
+		logger.jsonLogger.record(key, value)
+		return
+	}
+	logger.Debugf("%s=%v", key, value)
+}
@@ -0,0 +1,104 @@
+package logger
+
+import (
+	"runtime"
+	"strings"
+)
+
+// scope is an active logger, the loggers created by the functions it calls are nested under it
+type scope struct {
+	// functions are the full names of the functions that created the loggers of the path, outermost first
+	functions []string
+	// names are the short names of the same functions
+	names []string
+	line  *spinnerLine
+}
+
+func (s *scope) function() string {
+	return s.functions[len(s.functions)-1]
+}
+
+func shortFunctionName(fullFuncName string) string {
+	parts := strings.Split(fullFuncName, "/")
+	parts = strings.Split(parts[len(parts)-1], ".")
+	return parts[len(parts)-1]
+}
+
+// getCallerFunctions returns the full names of the calling functions, innermost first
+func getCallerFunctions(skipFrames int) []string {
+	programCounters := make([]uintptr, 64)
+	// skip runtime.Callers and getCallerFunctions
+	n := runtime.Callers(skipFrames+2, programCounters)
+	frames := runtime.CallersFrames(programCounters[:n])
+	var functions []string
+	for more := n > 0; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		functions = append(functions, frame.Function)
+	}
+	return functions
+}
+
+// indexOf finds the function in the callers, closures count as their enclosing function
+// so that tasks started on other goroutines by a function are nested under its logger
+func indexOf(functions []string, function string) int {
+	for i, candidate := range functions {
+		if candidate == function || strings.HasPrefix(candidate, function+".func") {
+			return i
+		}
+	}
+	return -1
+}
+
+// findParent picks the active scope of the innermost calling function, tasks run on several goroutines
+// so when scopes of concurrent tasks share a function, the one whose path matches more of the callers wins
+func findParent(callers []string) *scope {
+	var parent *scope
+	parentIndex, parentMatches := len(callers), 0
+	for _, candidate := range state.scopes {
+		index := indexOf(callers, candidate.function())
+		if index < 0 || index > parentIndex {
+			continue
+		}
+		matches := 0
+		for _, function := range candidate.functions {
+			if indexOf(callers, function) >= 0 {
+				matches++
+			}
+		}
+		// later scopes win ties, they are more recent
+		if index < parentIndex || matches >= parentMatches {
+			parent, parentIndex, parentMatches = candidate, index, matches
+		}
+	}
+	return parent
+}
+
+// openScope nests the logger of the calling function under its parent, skipFrames excludes the logger constructors
+func openScope(skipFrames int) *scope {
+	callers := getCallerFunctions(skipFrames + 1)
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	current := &scope{
+		functions: []string{callers[0]},
+		names:     []string{shortFunctionName(callers[0])},
+	}
+	if parent := findParent(callers[1:]); parent != nil {
+		current.functions = append(append([]string{}, parent.functions...), current.functions...)
+		current.names = append(append([]string{}, parent.names...), current.names...)
+		current.line = parent.line
+	}
+	state.scopes = append(state.scopes, current)
+	return current
+}
+
+func closeScope(closing *scope) {
+	state.mutex.Lock()
+	defer state.mutex.Unlock()
+	for i, candidate := range state.scopes {
+		if candidate == closing {
+			state.scopes = append(state.scopes[:i], state.scopes[i+1:]...)
+			return
+		}
+	}
+}
@@ -1,71 +1,225 @@
 package logger
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/docker/docker/pkg/term"
 	"github.com/kahgeh/devenv/utils/ctx"
 	"github.com/theckman/yacspin"
 )
 
+// Spinner renders one line per running task, tasks that do not depend on each other run concurrently,
+// completed tasks are printed above the running ones
 type Spinner struct {
-	logStream    chan string
-	newComponent chan *yacspin.Spinner
+	mutex       sync.Mutex
+	writer      io.Writer
+	output      io.Writer
+	lines       []*spinnerLine
+	painted     int
+	frame       int
+	interactive bool
 }
 
+// spinnerLine is the line of a task, nested loggers report on the line of their task
+type spinnerLine struct {
+	message        string
+	successMessage string
+	failMessage    string
+}
+
+const (
+	SucceedCompletionStatus = "✓"
+	FailedCompletionStatus  = "✗"
+	SkippedCompletionStatus = "-"
+)
+
 const (
-	SucceedCompletionStatus = "[succeed]"
-	FailedCompletionStatus  = "[failed]"
+	green = "\033[32m"
+	red   = "\033[31m"
+	reset = "\033[0m"
 )
 
-type CompletionStatus string
+var spinnerFrames = yacspin.CharSets[37]
 
 func slowDownForReading() {
 	time.Sleep(2 * time.Second)
 }
+
+func newSpinner() *Spinner {
+	_, interactive := term.GetFdInfo(os.Stderr)
+	return &Spinner{
+		writer:      os.Stderr,
+		output:      os.Stdout,
+		interactive: interactive,
+	}
+}
+
 func (spinner *Spinner) run() {
-	var component *yacspin.Spinner
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
 	for {
 		select {
-		case newComponent := <-spinner.newComponent:
-			component = newComponent
-			component.Start()
-		case message := <-spinner.logStream:
-			switch message {
-			case "[succeed]":
-				if component != nil {
-					component.Stop()
-				}
-				component = nil
-			case "[failed]":
-				if component != nil {
-					component.StopFail()
-				}
-				component = nil
-			default:
-				if component == nil {
-					panic("ensure a started spinner is available")
-				}
-				component.Message(message)
-				slowDownForReading()
-			}
+		case <-ticker.C:
+			spinner.mutex.Lock()
+			spinner.frame = (spinner.frame + 1) % len(spinnerFrames)
+			spinner.repaint()
+			spinner.mutex.Unlock()
 		case <-ctx.GetContext().Done():
-			if component != nil {
-				component.StopFail()
+			spinner.mutex.Lock()
+			spinner.erase()
+			for _, line := range spinner.lines {
+				spinner.printCompletion(FailedCompletionStatus, red, line.failMessage)
 			}
+			spinner.lines = nil
+			spinner.mutex.Unlock()
 			return
 		}
 	}
 }
 
-func (spinner *Spinner) update(message string) {
-	spinner.logStream <- message
+func (spinner *Spinner) width() int {
+	winsize, err := term.GetWinsize(os.Stderr.Fd())
+	if err != nil || winsize.Width == 0 {
+		return 80
+	}
+	return int(winsize.Width)
+}
+
+// erase moves the cursor back to the first running task line, the caller holds the mutex
+func (spinner *Spinner) erase() {
+	if spinner.painted == 0 {
+		return
+	}
+	fmt.Fprintf(spinner.writer, "\033[%dA\033[J", spinner.painted)
+	spinner.painted = 0
+}
+
+// repaint redraws the running task lines, the caller holds the mutex
+func (spinner *Spinner) repaint() {
+	if !spinner.interactive {
+		return
+	}
+	spinner.erase()
+	// the frame takes 2 columns and is followed by a space, messages longer than the terminal would wrap
+	maxWidth := spinner.width() - 4
+	for _, line := range spinner.lines {
+		message := []rune(line.message)
+		if maxWidth > 0 && len(message) > maxWidth {
+			message = message[:maxWidth]
+		}
+		fmt.Fprintf(spinner.writer, "%s %s\n", spinnerFrames[spinner.frame], string(message))
+	}
+	spinner.painted = len(spinner.lines)
+}
+
+// printCompletion writes the final line of a task, the caller holds the mutex
+func (spinner *Spinner) printCompletion(character string, color string, message string) {
+	if spinner.interactive && color != "" {
+		character = color + character + reset
+	}
+	fmt.Fprintf(spinner.writer, "%s %s\n", character, message)
 }
 
-func (spinner *Spinner) failed(message string) {
-	spinner.logStream <- message
-	spinner.logStream <- FailedCompletionStatus
+func (spinner *Spinner) start(message string, successMessage string, failMessage string) *spinnerLine {
+	line := &spinnerLine{
+		message:        message,
+		successMessage: successMessage,
+		failMessage:    failMessage,
+	}
+	spinner.mutex.Lock()
+	defer spinner.mutex.Unlock()
+	spinner.lines = append(spinner.lines, line)
+	spinner.repaint()
+	return line
 }
 
-func (spinner *Spinner) succeed() {
-	spinner.logStream <- SucceedCompletionStatus
+// complete replaces the running line of the task with its final line
+func (spinner *Spinner) complete(line *spinnerLine, character string, color string, message string) {
+	spinner.mutex.Lock()
+	defer spinner.mutex.Unlock()
+	index := -1
+	for i, candidate := range spinner.lines {
+		if candidate == line {
+			index = i
+		}
+	}
+	if index < 0 {
+		return
+	}
+	spinner.erase()
+	spinner.printCompletion(character, color, message)
+	spinner.lines = append(spinner.lines[:index], spinner.lines[index+1:]...)
+	spinner.repaint()
+}
+
+// update shows the message on the line of the task long enough for it to be read
+func (spinner *Spinner) update(line *spinnerLine, message string) {
+	if line == nil {
+		return
+	}
+	spinner.progress(line, message)
+	slowDownForReading()
+}
+
+// progress updates the message without pausing for it to be read, for frequent updates
+func (spinner *Spinner) progress(line *spinnerLine, message string) {
+	if line == nil {
+		return
+	}
+	spinner.mutex.Lock()
+	defer spinner.mutex.Unlock()
+	line.message = message
+	spinner.repaint()
+}
+
+func (spinner *Spinner) print(text string) {
+	spinner.mutex.Lock()
+	defer spinner.mutex.Unlock()
+	spinner.erase()
+	fmt.Fprint(spinner.output, text)
+	if !strings.HasSuffix(text, "\n") {
+		// keep the running lines below the text
+		fmt.Fprintln(spinner.output)
+	}
+	spinner.repaint()
+}
+
+// ask pauses the running lines while the user answers the question
+func (spinner *Spinner) ask(question string, readAnswer func() (string, error)) (string, error) {
+	spinner.mutex.Lock()
+	defer spinner.mutex.Unlock()
+	spinner.erase()
+	fmt.Fprint(spinner.writer, question)
+	answer, err := readAnswer()
+	spinner.repaint()
+	return answer, err
+}
+
+func (spinner *Spinner) failed(line *spinnerLine) {
+	if line == nil {
+		return
+	}
+	spinner.complete(line, FailedCompletionStatus, red, line.failMessage)
+}
+
+func (spinner *Spinner) skipped(line *spinnerLine, message string) {
+	if line == nil {
+		return
+	}
+	spinner.complete(line, SkippedCompletionStatus, "", message)
+}
+
+func (spinner *Spinner) succeed(line *spinnerLine, message string) {
+	if line == nil {
+		return
+	}
+	if message == "" {
+		message = line.successMessage
+	}
+	spinner.complete(line, SucceedCompletionStatus, green, message)
 }
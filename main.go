@@ -16,14 +16,21 @@ limitations under the License.
 package main
 
 import (
+	"os"
+
 	"github.com/kahgeh/devenv/cmd"
 	"github.com/kahgeh/devenv/logger"
 	"github.com/kahgeh/devenv/utils/ctx"
 )
 
-func main() {
+// run executes the command, its defers complete before main exits with the returned code
+func run() int {
 	defer logger.Sync()
 	defer ctx.CleanUp()
 	go ctx.WaitOnCtrlCSignalOrCompletion()
-	cmd.Execute()
+	return cmd.Execute()
+}
+
+func main() {
+	os.Exit(run())
 }
@@ -1,19 +1,43 @@
 package aws
 
 import (
+	"context"
 	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/journal"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils/ctx"
 )
 
 type Template string
 
 const (
 	TemplateParamStoreKeyPath Template = "/allEnvs/%s/pstoreKey"
+	TemplateAppSecretsPath    Template = "/allEnvs/%s/apps/%s/secrets"
+	TemplateAppReleasesPath   Template = "/allEnvs/%s/apps/%s/releases"
 )
 
 type Session struct {
 	config aws.Config
+	// computeConfig names the stacks and resources of the environment
+	computeConfig Config
+	// plan only previews the changes, stacks and resources are left untouched
+	plan bool
+	// autoRecover deletes and re-creates stacks that can no longer be updated
+	autoRecover bool
+	// ctx cancels the aws calls of the session, see WithContext
+	ctx context.Context
+	// journal records the completed steps so that interrupted workflows resume, fresh ignores it
+	journal *journal.Journal
+	fresh   bool
+	// workflow and step are the step being run, see runStep
+	workflow *journal.Workflow
+	step     string
 }
 
 type Config struct {
@@ -21,35 +45,207 @@ type Config struct {
 	EcsSpotFleetStackName string
 	EcsClusterStackName   string
 	PublicIPStackName     string
+	ShutdownStackName     string
+	ReattachStackName     string
 	EcsClusterName        string
 	EcsSpotFleetPurpose   string
 	KeyPairName           string
 	HostedZoneName        string
+	// AppStackPrefix is shared by the stack names of every app deployed to the environment
+	AppStackPrefix string
+	// EcrStackPrefix is shared by the stack names of every app repository of the environment
+	EcrStackPrefix string
+	// RepositoryPrefix namespaces the ecr repositories, repository names must be lower case
+	RepositoryPrefix string
+	// VpcExportPrefix is prepended to the names of the vpc stack exports the spot fleet imports
+	VpcExportPrefix string
+}
+
+// stackNamePattern is what cloudformation accepts as a stack name
+var stackNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// environments created before the names were derived from the environment name used the names of DevTest whatever
+// their environment name, their spot fleet stack is named legacySpotFleetStackName and their vpc exports start with
+// legacyVpcExportPrefix
+const (
+	legacyBaseName           = "DevTest"
+	legacySpotFleetStackName = "GeneralPurposeEcs"
+	legacyVpcExportPrefix    = "Dev"
+)
+
+// newComputeConfig derives the names of the environment resources from the optional prefix and the environment name
+func newComputeConfig(namePrefix string, envName string, region string) (Config, error) {
+	baseName := namePrefix + envName
+	if !stackNamePattern.MatchString(baseName) {
+		return Config{}, fmt.Errorf("%q cannot be used to name stacks, "+
+			"the name prefix and environment name must start with a letter and only contain letters, digits and hyphens", baseName)
+	}
+	return Config{
+		VpcStackName:          baseName,
+		EcsSpotFleetStackName: fmt.Sprintf("%sGeneralPurposeEcs", baseName),
+		EcsClusterStackName:   fmt.Sprintf("%sEcsCluster", baseName),
+		PublicIPStackName:     fmt.Sprintf("%sPublicIp", baseName),
+		ShutdownStackName:     fmt.Sprintf("%sShutdown", baseName),
+		ReattachStackName:     fmt.Sprintf("%sReattach", baseName),
+		EcsClusterName:        baseName,
+		EcsSpotFleetPurpose:   "gp",
+		KeyPairName:           fmt.Sprintf("ecs-instance-%s-%v", baseName, region),
+		AppStackPrefix:        fmt.Sprintf("%s-app-", baseName),
+		EcrStackPrefix:        fmt.Sprintf("%s-ecr-", baseName),
+		RepositoryPrefix:      fmt.Sprintf("%s/", strings.ToLower(baseName)),
+		VpcExportPrefix:       fmt.Sprintf("%s-", baseName),
+	}, nil
 }
 
-var awsComputeConfig = Config{
-	VpcStackName:          "DevTest",
-	EcsSpotFleetStackName: "GeneralPurposeEcs",
-	EcsClusterStackName:   "DevTestEcsCluster",
-	PublicIPStackName:     "DevTestPublicIp",
-	EcsClusterName:        "DevTest",
-	EcsSpotFleetPurpose:   "gp",
+// newLegacyComputeConfig keeps the names of an environment created by an earlier version, renaming them would replace
+// its spot fleet, key pair, apps and repositories, and its vpc exports cannot change while the spot fleet imports them
+func newLegacyComputeConfig(region string) Config {
+	config, _ := newComputeConfig("", legacyBaseName, region)
+	config.EcsSpotFleetStackName = legacySpotFleetStackName
+	config.KeyPairName = fmt.Sprintf("ecs-instance-%v", region)
+	config.AppStackPrefix = "app-"
+	config.EcrStackPrefix = "ecr-"
+	config.RepositoryPrefix = ""
+	config.VpcExportPrefix = legacyVpcExportPrefix
+	return config
 }
 
-func CreateAwsSession() (*Session, error) {
+// findLegacyEnvironment reports whether an environment created by an earlier version exists, either its spot fleet
+// stack or its vpc exports still have the old names, envName is the environment its spot fleet was created for,
+// empty when only the vpc is left
+func (session *Session) findLegacyEnvironment() (found bool, envName string, err error) {
+	spotFleet := NewStack(legacySpotFleetStackName, session)
+	description, err := spotFleet.Describe()
+	if err != nil {
+		return false, "", err
+	}
+	if description != nil {
+		for _, parameter := range description.Parameters {
+			if aws.StringValue(parameter.ParameterKey) == "Environment" {
+				// the setup of earlier versions saved the environment name with its new line
+				envName = strings.TrimSpace(aws.StringValue(parameter.ParameterValue))
+			}
+		}
+		return true, envName, nil
+	}
+	vpc, err := NewStack(legacyBaseName, session).Describe()
+	if err != nil || vpc == nil {
+		return false, "", err
+	}
+	for _, output := range vpc.Outputs {
+		if aws.StringValue(output.ExportName) == legacyVpcExportPrefix+"VpcId" {
+			return true, "", nil
+		}
+	}
+	return false, "", nil
+}
+
+// getLegacyComputeConfig returns the names of the environment created by an earlier version when there is one, it is
+// only used with its own environment name and without a name prefix, as a second environment would run beside it
+// unnoticed
+func (session *Session) getLegacyComputeConfig(options *types.SessionOptions, region string) (*Config, error) {
+	found, envName, err := session.findLegacyEnvironment()
+	if err != nil {
+		return nil, types.NewAwsError("look for an environment created by an earlier version", err)
+	}
+	if !found {
+		return nil, nil
+	}
+	if options.NamePrefix == "" && (envName == "" || envName == options.EnvironmentName) {
+		config := newLegacyComputeConfig(region)
+		return &config, nil
+	}
+	usage := "without --name-prefix"
+	if envName != "" {
+		usage = fmt.Sprintf("with --env-name %s and without --name-prefix", envName)
+	}
+	return nil, types.NewUserError("name the environment resources",
+		fmt.Errorf("the environment created by an earlier version with the stacks %s and %s still exists, "+
+			"run the commands %s to keep using it, or devenv teardown %s to remove it before creating another one",
+			legacyBaseName, legacySpotFleetStackName, usage, usage))
+}
+
+// GetAppStackName returns the name of the stack that runs the app
+func (config Config) GetAppStackName(appName string) string {
+	return config.AppStackPrefix + appName
+}
+
+// GetEcrStackName returns the name of the stack that holds the app repository
+func (config Config) GetEcrStackName(appName string) string {
+	return config.EcrStackPrefix + appName
+}
+
+// GetRepositoryName returns the name of the app repository
+func (config Config) GetRepositoryName(appName string) string {
+	return config.RepositoryPrefix + strings.ToLower(appName)
+}
+
+func CreateAwsSession(options *types.SessionOptions) (*Session, error) {
 	cfg, err := external.LoadDefaultAWSConfig()
 	if err != nil {
-		panic("unable to load SDK config, " + err.Error())
+		return nil, types.NewUserError("load aws config", err)
+	}
+	computeConfig, err := newComputeConfig(options.NamePrefix, options.EnvironmentName, cfg.Region)
+	if err != nil {
+		return nil, types.NewUserError("name the environment resources", err)
+	}
+	session := &Session{
+		config:        cfg,
+		computeConfig: computeConfig,
+		plan:          options.Plan,
+		autoRecover:   options.AutoRecover,
+		fresh:         options.Fresh,
+	}
+	legacyConfig, err := session.getLegacyComputeConfig(options, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	if legacyConfig != nil {
+		session.computeConfig = *legacyConfig
+	}
+	// the journal is named after the resources so that prefixed environments do not share it
+	journalName := session.computeConfig.VpcStackName
+	stateJournal, err := journal.Open(journalName)
+	if err != nil && !options.Fresh {
+		return nil, types.NewUserError("read the state journal", fmt.Errorf("%w, run with --fresh to start over", err))
+	}
+	if err != nil {
+		stateJournal = journal.New(journalName)
 	}
-	session := &Session{config: cfg}
-	awsComputeConfig.KeyPairName = session.GetKeyPairName()
+	session.journal = stateJournal
+	ctx.SetInterruptHandler(session.handleInterrupt)
 	return session, nil
 }
 
+// WithContext returns a copy of the session whose aws calls are cancelled with the context,
+// e.g. when a step running concurrently fails
+func (session *Session) WithContext(operationCtx context.Context) *Session {
+	copied := *session
+	copied.ctx = operationCtx
+	return &copied
+}
+
+func (session *Session) getContext() context.Context {
+	if session.ctx == nil {
+		return ctx.GetContext()
+	}
+	return session.ctx
+}
+
 func (session *Session) GetKeyPairName() string {
-	return fmt.Sprintf("ecs-instance-%v", *&session.config.Region)
+	return session.computeConfig.KeyPairName
 }
 
 func (session *Session) GetComputeConfig() Config {
-	return awsComputeConfig
+	return session.computeConfig
+}
+
+// skipWhenPlanning completes the task without doing the action when only previewing changes
+func (session *Session) skipWhenPlanning(log *logger.Logger, action string) bool {
+	if !session.plan {
+		return false
+	}
+	log.Print(fmt.Sprintf("\nplan: %s\n", action))
+	log.Skipf("skipped, plan only")
+	return true
 }
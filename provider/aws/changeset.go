@@ -3,12 +3,12 @@ package aws
 import (
 	"fmt"
 	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/kahgeh/devenv/logger"
-	"github.com/kahgeh/devenv/utils/ctx"
 )
 
 var ChangeSetCompletionStatuses = []cloudformation.ChangeSetStatus{
@@ -34,12 +34,12 @@ func containStatus(status cloudformation.ChangeSetStatus, statuses []cloudformat
 
 }
 
-func waitForChangesetCompletion(poll func() (bool, *cloudformation.DescribeChangeSetResponse), result chan *cloudformation.DescribeChangeSetResponse) {
+func waitForChangesetCompletion(done <-chan struct{}, poll func() (bool, *cloudformation.DescribeChangeSetResponse), result chan *cloudformation.DescribeChangeSetResponse) {
 	ticker := time.NewTicker(2 * time.Second)
 	defer func() { ticker.Stop() }()
 	for {
 		select {
-		case <-ctx.GetContext().Done():
+		case <-done:
 			result <- nil
 			return
 		case <-ticker.C:
@@ -51,47 +51,54 @@ func waitForChangesetCompletion(poll func() (bool, *cloudformation.DescribeChang
 	}
 }
 
-func (changeset *ChangeSet) WaitTillExecutable() *ChangeSet {
+// isWithoutChanges is true when the change set failed only because the stack is already up to date
+func isWithoutChanges(details *cloudformation.DescribeChangeSetResponse) bool {
+	return details.Status == cloudformation.ChangeSetStatusFailed &&
+		strings.Contains(aws.StringValue(details.StatusReason), "didn't contain changes")
+}
+
+func (changeset *ChangeSet) WaitTillExecutable() (*ChangeSet, error) {
 	log := logger.New()
 	defer log.LogDone()
-	if changeset == nil {
-		return nil
-	}
 	// todo - switch to waiter
 	resultChannel := make(chan *cloudformation.DescribeChangeSetResponse)
-	go waitForChangesetCompletion(func() (bool, *cloudformation.DescribeChangeSetResponse) {
-		description := changeset.Describe()
+	var describeErr error
+	go waitForChangesetCompletion(changeset.stack.ctx.Done(), func() (bool, *cloudformation.DescribeChangeSetResponse) {
+		description, err := changeset.Describe()
+		if err != nil {
+			describeErr = err
+			return true, nil
+		}
 		return containStatus(description.Status, ChangeSetCompletionStatuses), description
 	}, resultChannel)
 	result := <-resultChannel
+	if describeErr != nil {
+		return nil, describeErr
+	}
 	if result == nil {
-		return nil
+		return nil, changeset.stack.ctx.Err()
 	}
 
-	if result.Status == cloudformation.ChangeSetStatusFailed {
-		log.Failf("Fail to create changeset %s", changeset.name)
-		return nil
+	if result.Status == cloudformation.ChangeSetStatusFailed && !changeset.stack.planOnly && !isWithoutChanges(result) {
+		return nil, fmt.Errorf("change set %s failed, %s", changeset.name, aws.StringValue(result.StatusReason))
 	}
 
 	return &ChangeSet{
 		stack:   changeset.stack,
 		id:      changeset.id,
+		name:    changeset.name,
 		details: result,
-	}
+	}, nil
 }
 
-func (changeset *ChangeSet) Describe() *cloudformation.DescribeChangeSetResponse {
+func (changeset *ChangeSet) Describe() (*cloudformation.DescribeChangeSetResponse, error) {
 	stackName := changeset.stack.name
 	api := changeset.stack.api
 	request := api.DescribeChangeSetRequest(&cloudformation.DescribeChangeSetInput{
 		StackName:     &stackName,
 		ChangeSetName: aws.String(changeset.name),
 	})
-	response, err := request.Send(ctx.GetContext())
-	if err != nil {
-		return nil
-	}
-	return response
+	return request.Send(changeset.stack.ctx)
 }
 
 func (changeset *ChangeSet) String() string {
@@ -111,12 +118,51 @@ func getChangesSummary(changes []cloudformation.Change) string {
 	return sb.String()
 }
 
-func (changeset *ChangeSet) SendExecuteRequest() *string {
+// GetPlan describes the changes as a table of action, logical id, resource type and replacement
+func (changeset *ChangeSet) GetPlan() string {
+	if changeset == nil || changeset.details == nil {
+		return ""
+	}
+	var sb strings.Builder
+	details := changeset.details
+	sb.WriteString(fmt.Sprintf("\nplan for stack %q\n", changeset.stack.name))
+	if details.Status == cloudformation.ChangeSetStatusFailed {
+		sb.WriteString(fmt.Sprintf("  %s\n", aws.StringValue(details.StatusReason)))
+		return sb.String()
+	}
+
+	table := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "  ACTION\tLOGICAL ID\tRESOURCE TYPE\tREPLACEMENT")
+	for _, change := range details.Changes {
+		resourceChange := change.ResourceChange
+		replacement := string(resourceChange.Replacement)
+		if replacement == "" {
+			replacement = "-"
+		}
+		_, _ = fmt.Fprintf(table, "  %s\t%s\t%s\t%s\n",
+			resourceChange.Action,
+			aws.StringValue(resourceChange.LogicalResourceId),
+			aws.StringValue(resourceChange.ResourceType),
+			replacement)
+	}
+	_ = table.Flush()
+	return sb.String()
+}
+
+// Delete removes the change set without executing it
+func (changeset *ChangeSet) Delete() error {
+	api := changeset.stack.api
+	request := api.DeleteChangeSetRequest(&cloudformation.DeleteChangeSetInput{
+		ChangeSetName: aws.String(changeset.id),
+	})
+	_, err := request.Send(changeset.stack.ctx)
+	return err
+}
+
+// SendExecuteRequest starts the change set, the returned token identifies the events of the operation
+func (changeset *ChangeSet) SendExecuteRequest() (*string, error) {
 	log := logger.New()
 	defer log.LogDone()
-	if changeset == nil {
-		return nil
-	}
 	stackName := changeset.stack.name
 	api := changeset.stack.api
 	token := fmt.Sprintf("%s-%v", stackName, time.Now().UnixNano())
@@ -128,11 +174,8 @@ func (changeset *ChangeSet) SendExecuteRequest() *string {
 		ClientRequestToken: &token,
 	})
 
-	_, err := request.Send(ctx.GetContext())
-	if err != nil {
-		log.Infof("error executeChange- %s", err)
-		return nil
+	if _, err := request.Send(changeset.stack.ctx); err != nil {
+		return nil, err
 	}
-
-	return &token
+	return &token, nil
 }
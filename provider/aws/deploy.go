@@ -6,10 +6,13 @@ import (
 	"fmt"
 	ecs2 "github.com/aws/aws-sdk-go-v2/service/ecs"
 	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/provider/manifest"
 	provideTypes "github.com/kahgeh/devenv/provider/types"
 	"github.com/kahgeh/devenv/utils"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -18,11 +21,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/docker/docker/api/types"
 	whale "github.com/docker/docker/client"
-	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/term"
 	"github.com/kahgeh/devenv/logger"
-	"github.com/kahgeh/devenv/utils/ctx"
+	"github.com/kahgeh/devenv/provider/docker"
 )
 
 func generateID() string {
@@ -45,151 +47,159 @@ func getAuthToken(authResponse *ecr.GetAuthorizationTokenResponse) string {
 	return base64.StdEncoding.EncodeToString(authBytes)
 }
 
-func buildImage(context string, appName string, id string, buildArgs map[string]*string) *string {
+func (session *Session) createRepository(appName string) (*string, error) {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
-	client, err := whale.NewClientWithOpts()
-	if err != nil {
-		log.Fail("cannot access docker")
-		return nil
-	}
-
-	buildCtx, _ := archive.TarWithOptions(context, &archive.TarOptions{})
-	tag := fmt.Sprintf("%s:%s", appName, id)
-	response, err := client.ImageBuild(ctx.GetContext(),
-		buildCtx,
-		types.ImageBuildOptions{
-			Tags:      []string{tag},
-			BuildArgs: buildArgs,
-			NoCache:   true,
-		})
-
+	config := session.GetComputeConfig()
+	stackName := config.GetEcrStackName(appName)
+	stack := NewStack(stackName, session)
+	stackDescription, err := stack.Describe()
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("build failed")
-		return nil
+		err = provideTypes.NewAwsError("get repository details", err)
+		log.Fail(err)
+		return nil, err
 	}
-
-	defer utils.CloseReadCloser(response.Body, func(s string) { log.Debug(s) })
-	log.DebugFunc(func() {
-		termFd, isTerm := term.GetFdInfo(os.Stderr)
-		err := jsonmessage.DisplayJSONMessagesStream(response.Body, os.Stderr, termFd, isTerm, nil)
-		if err != nil {
-			log.Debug(err.Error())
-		}
-	}, func() {
-		_, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fail(err.Error())
-		}
-	})
-	log.Succeed()
-	return &tag
-}
-
-func getEcrStackName(appName string) string {
-	return fmt.Sprintf("ecr-%s", appName)
-}
-
-func (session *Session) createRepository(appName string) *string {
-	log := logger.NewTaskLogger()
-	defer log.LogDone()
-	stackName := getEcrStackName(appName)
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
-	stackDescription, err := stack.Describe()
 	if stackDescription != nil {
 		log.Succeed()
-		return stackDescription.Outputs[0].OutputValue
+		return stackDescription.Outputs[0].OutputValue, nil
 	}
 
 	err = stack.Create("ecr.yml", []cloudformation.Parameter{
 		{
 			ParameterKey:   aws.String("RepositoryName"),
-			ParameterValue: aws.String(appName),
+			ParameterValue: aws.String(config.GetRepositoryName(appName)),
 		},
 	})
 	if err != nil {
-		log.Fail("fail to create repository")
+		err = provideTypes.NewAwsError("create repository", err)
+		log.Fail(err)
+		return nil, err
+	}
+	if session.plan {
+		log.Succeed()
+		return aws.String(fmt.Sprintf("<%s repository>", appName)), nil
 	}
 
-	repository := session.getStackOutputValue(stackName, stackName)
+	repository, err := session.getStackOutputValue(stackName, stackName)
+	if err != nil {
+		err = provideTypes.NewAwsError("get repository details", err)
+		log.Fail(err)
+		return nil, err
+	}
 	log.Succeed()
-	return repository
+	return repository, nil
+}
+
+// pushImage pushes the image to the registry and waits for the push to complete
+func (session *Session) pushImage(client *whale.Client, image string, authToken string) error {
+	log := logger.New()
+	defer log.LogDone()
+	response, err := client.ImagePush(
+		session.getContext(),
+		image,
+		types.ImagePushOptions{
+			RegistryAuth: authToken,
+		})
+	if err != nil {
+		return err
+	}
+	defer utils.CloseReadCloser(response, func(s string) { log.Debug(s) })
+	log.DebugFunc(func() {
+		termFd, isTerm := term.GetFdInfo(os.Stderr)
+		err = jsonmessage.DisplayJSONMessagesStream(response, os.Stderr, termFd, isTerm, nil)
+	}, func() {
+		err = jsonmessage.DisplayJSONMessagesStream(response, ioutil.Discard, 0, false, nil)
+	})
+	return err
 }
 
-func (session *Session) uploadImage(source *string, repo *string, tag string) {
+func (session *Session) uploadImage(source *string, repo *string, tag string) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
+	target := fmt.Sprintf("%s:%s", *repo, tag)
+	if session.skipWhenPlanning(log, fmt.Sprintf("build and push image %s", target)) {
+		return nil
+	}
 	client, err := whale.NewClientWithOpts()
 	if err != nil {
-		log.Fail("cannot access docker")
-		return
+		err = provideTypes.NewDockerError("access docker", err)
+		log.Fail(err)
+		return err
 	}
-	target := fmt.Sprintf("%s:%s", *repo, tag)
 	log.Info("tagging image with repo prefix...")
-	err = client.ImageTag(ctx.GetContext(), *source, target)
+	err = client.ImageTag(session.getContext(), *source, target)
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("Fail to tag image")
-		return
+		err = provideTypes.NewDockerError("tag image", err)
+		log.Fail(err)
+		return err
 	}
-	err = client.ImageTag(ctx.GetContext(), *source, *repo)
+	err = client.ImageTag(session.getContext(), *source, *repo)
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("Fail to tag image with latest")
-		return
+		err = provideTypes.NewDockerError("tag image with latest", err)
+		log.Fail(err)
+		return err
 	}
 
 	log.Info("pushing image to repository...")
 	svc := ecr.New(session.config)
 	request := svc.GetAuthorizationTokenRequest(&ecr.GetAuthorizationTokenInput{})
-	authResponse, err := request.Send(ctx.GetContext())
+	authResponse, err := request.Send(session.getContext())
+	if err != nil {
+		err = provideTypes.NewAwsError("get registry authorization token", err)
+		log.Fail(err)
+		return err
+	}
 	authToken := getAuthToken(authResponse)
-	response, err := client.ImagePush(
-		ctx.GetContext(),
-		target,
-		types.ImagePushOptions{
-			RegistryAuth: authToken,
-		})
-
-	response, err = client.ImagePush(
-		ctx.GetContext(),
-		*repo,
-		types.ImagePushOptions{
-			RegistryAuth: authToken,
-		})
+	for _, image := range []string{target, *repo} {
+		if err := session.pushImage(client, image, authToken); err != nil {
+			err = provideTypes.NewDockerError(fmt.Sprintf("push %s to repository", image), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeed()
+	return nil
+}
 
+// removeService scales the service to 0 and waits till its tasks are stopped
+func (session *Session) removeService(envName string, appName string) error {
+	log := logger.New()
+	defer log.LogDone()
+	ssmSession := session.NewSsmSession()
+	log.Info("getting app details...")
+	cluster, err := ssmSession.GetParameterValue(fmt.Sprintf("/allEnvs/%s/infra/ecs/name", envName))
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("fail to push to registry")
-		return
+		return provideTypes.NewAwsError("get cluster name details", err)
 	}
 
-	if response == nil {
-		log.Fail("fail to push to registry, no response")
-		return
+	serviceArn, err := ssmSession.GetParameterValue(fmt.Sprintf("/allEnvs/%s/apps/%s/serviceArn", envName, appName))
+	if err != nil {
+		return provideTypes.NewAwsError("get service details", err)
 	}
 
-	defer utils.CloseReadCloser(response, func(s string) { log.Debug(s) })
-	log.DebugFunc(func() {
-		termFd, isTerm := term.GetFdInfo(os.Stderr)
-		err := jsonmessage.DisplayJSONMessagesStream(response, os.Stderr, termFd, isTerm, nil)
-		if err != nil {
-			log.Debug(err.Error())
-			log.Fail("fail to push image to repository")
-		}
-	}, func() {
-		_, err := ioutil.ReadAll(response)
-		if err != nil {
-			log.Debug(err.Error())
-			log.Fail("fail to push image to repository")
-		}
+	log.Info("removing app...")
+	api := ecs2.New(session.config)
+	req := api.UpdateServiceRequest(&ecs2.UpdateServiceInput{
+		Cluster:      cluster,
+		Service:      serviceArn,
+		DesiredCount: aws.Int64(0),
 	})
-	log.Succeed()
+	_, err = req.Send(session.getContext())
+	if err != nil {
+		return provideTypes.NewAwsError("remove app first", err)
+	}
+	log.Info("waiting app to be removed...")
+	err = api.WaitUntilServicesStable(session.getContext(), &ecs2.DescribeServicesInput{
+		Cluster:  cluster,
+		Services: []string{*serviceArn},
+	})
+	if err != nil {
+		return provideTypes.NewAwsError("wait for app to be removed", err)
+	}
+	return nil
 }
 
-func (session *Session) deployFrontProxy(image string, envName string) {
+func (session *Session) deployFrontProxy(image string, envName string) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
@@ -198,7 +208,7 @@ func (session *Session) deployFrontProxy(image string, envName string) {
 	paramStoreKeyPath := fmt.Sprintf(string(TemplateParamStoreKeyPath), envName)
 	log.Infof("image=%s envName%s", image, envName)
 
-	stackName := fmt.Sprintf("app-%s", appName)
+	stackName := config.GetAppStackName(appName)
 	parameters := []cloudformation.Parameter{
 		{
 			ParameterKey:   aws.String("AppName"),
@@ -222,48 +232,19 @@ func (session *Session) deployFrontProxy(image string, envName string) {
 		},
 	}
 	templateFileName := "front-proxy/app.yml"
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
+	stack := NewStack(stackName, session)
 	stackDescription, err := stack.Describe()
+	if err != nil {
+		err = provideTypes.NewAwsError(fmt.Sprintf("get %s details", appName), err)
+		log.Fail(err)
+		return err
+	}
 	if stackDescription != nil {
-		ssmSession := session.NewSsmSession()
-		log.Info("getting app details...")
-		cluster, err := ssmSession.GetParameterValue(fmt.Sprintf("/allEnvs/%s/infra/ecs/name", envName))
-		if err != nil {
-			log.Debug(err.Error())
-			log.Fail("fail to get cluster name details")
-			return
-		}
-
-		serviceArn, err := ssmSession.GetParameterValue(fmt.Sprintf("/allEnvs/%s/apps/%s/serviceArn", envName, appName))
-		if err != nil {
-			log.Debug(err.Error())
-			log.Fail("fail to get service details")
-			return
-		}
-
-		log.Info("removing app...")
-		api := ecs2.New(session.config)
-		req := api.UpdateServiceRequest(&ecs2.UpdateServiceInput{
-			Cluster:      cluster,
-			Service:      serviceArn,
-			DesiredCount: aws.Int64(0),
-		})
-		_, err = req.Send(ctx.GetContext())
-		if err != nil {
-			log.Debug(err.Error())
-			log.Fail("fail to remove app first")
-			return
-		}
-		log.Info("waiting app to be removed...")
-		err = api.WaitUntilServicesStable(ctx.GetContext(), &ecs2.DescribeServicesInput{
-			Cluster:  cluster,
-			Services: []string{*serviceArn},
-		})
-		if err != nil {
-			log.Debug(err.Error())
-			log.Fail("waiting for app to be removed failed")
-			return
+		if session.plan {
+			log.Print(fmt.Sprintf("\nplan: scale %s service to 0 before updating\n", appName))
+		} else if err := session.removeService(envName, appName); err != nil {
+			log.Fail(err)
+			return err
 		}
 		log.Info("updating app...")
 		err = stack.Update(templateFileName, parameters)
@@ -272,14 +253,50 @@ func (session *Session) deployFrontProxy(image string, envName string) {
 		err = stack.Create(templateFileName, parameters)
 	}
 	if err != nil {
-		log.Debug(err.Error())
-		log.Failf("fail to deploy %s", appName)
+		err = provideTypes.NewAwsError(fmt.Sprintf("deploy %s", appName), err)
+		log.Fail(err)
+		return err
 	}
 
+	log.Result("appStack", stackName)
 	log.Succeed()
+	return nil
+}
+
+// appTemplateData is what app.yml is rendered with, the lists that cannot be passed as stack parameters
+type appTemplateData struct {
+	Environment  map[string]string
+	DockerLabels map[string]string
+	// Secrets are the parameters of the secrets by key
+	Secrets map[string]string
+}
+
+func getManifestParameters(appManifest *manifest.Manifest) []cloudformation.Parameter {
+	values := [][]string{
+		{"ContainerPort", strconv.FormatInt(appManifest.ContainerPort, 10)},
+		{"Cpu", strconv.FormatInt(appManifest.Cpu, 10)},
+		{"MemoryReservation", strconv.FormatInt(appManifest.MemoryReservation, 10)},
+		{"DesiredCount", strconv.FormatInt(appManifest.DesiredCount, 10)},
+	}
+	if healthCheck := appManifest.HealthCheck; healthCheck != nil {
+		values = append(values,
+			[]string{"HealthCheckCommand", healthCheck.Command},
+			[]string{"HealthCheckInterval", strconv.FormatInt(int64(healthCheck.Interval.Seconds()), 10)},
+			[]string{"HealthCheckTimeout", strconv.FormatInt(int64(healthCheck.Timeout.Seconds()), 10)},
+			[]string{"HealthCheckRetries", strconv.FormatInt(healthCheck.Retries, 10)},
+			[]string{"HealthCheckStartPeriod", strconv.FormatInt(int64(healthCheck.StartPeriod.Seconds()), 10)})
+	}
+	var parameters []cloudformation.Parameter
+	for _, keyAndValue := range values {
+		parameters = append(parameters, cloudformation.Parameter{
+			ParameterKey:   aws.String(keyAndValue[0]),
+			ParameterValue: aws.String(keyAndValue[1]),
+		})
+	}
+	return parameters
 }
 
-func (session *Session) deployApp(appName string, image string, envName string) {
+func (session *Session) deployApp(appName string, image string, envName string, appManifest *manifest.Manifest) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
@@ -287,7 +304,7 @@ func (session *Session) deployApp(appName string, image string, envName string)
 
 	log.Info("getting app details...")
 
-	stackName := fmt.Sprintf("app-%s", appName)
+	stackName := config.GetAppStackName(appName)
 	parameters := []cloudformation.Parameter{
 		{
 			ParameterKey:   aws.String("AppName"),
@@ -305,11 +322,39 @@ func (session *Session) deployApp(appName string, image string, envName string)
 			ParameterKey:   aws.String("EnvironmentName"),
 			ParameterValue: aws.String(envName),
 		},
+		{
+			ParameterKey:   aws.String("ParamStoreKeyArn"),
+			ParameterValue: aws.String(fmt.Sprintf(string(TemplateParamStoreKeyPath), envName)),
+		},
+	}
+	parameters = append(parameters, getManifestParameters(appManifest)...)
+
+	secrets, err := session.getSecretParameterNames(envName, appName)
+	if err != nil {
+		err = provideTypes.NewAwsError(fmt.Sprintf("list secrets of %s", appName), err)
+		log.Fail(err)
+		return err
+	}
+	for key := range secrets {
+		if _, declared := appManifest.Environment[key]; declared {
+			err = provideTypes.NewUserError(fmt.Sprintf("deploy %s", appName),
+				fmt.Errorf("%s is both a secret and an environment variable of %s", key, manifest.FileName))
+			log.Fail(err)
+			return err
+		}
 	}
 	templateFileName := "app.yml"
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
+	stack := NewStack(stackName, session).WithTemplateData(appTemplateData{
+		Environment:  appManifest.Environment,
+		DockerLabels: appManifest.Labels(appName),
+		Secrets:      secrets,
+	})
 	stackDescription, err := stack.Describe()
+	if err != nil {
+		err = provideTypes.NewAwsError(fmt.Sprintf("get %s details", appName), err)
+		log.Fail(err)
+		return err
+	}
 	if stackDescription != nil {
 		log.Info("updating app...")
 		err = stack.Update(templateFileName, parameters)
@@ -318,15 +363,91 @@ func (session *Session) deployApp(appName string, image string, envName string)
 		err = stack.Create(templateFileName, parameters)
 	}
 	if err != nil {
-		log.Debug(err.Error())
-		log.Failf("fail to deploy %s", appName)
+		err = provideTypes.NewAwsError(fmt.Sprintf("deploy %s", appName), err)
+		log.Fail(err)
+		return err
 	}
 
+	log.Result("appStack", stackName)
 	log.Succeed()
+	return nil
 }
 
-// Deploy builds, publish image and deploy service
-func (session *Session) Deploy(parameters *provideTypes.DeployParameters) {
+// deployImage creates or updates the stack that runs the image as the app
+func (session *Session) deployImage(parameters *provideTypes.DeployParameters, appName string, image string) error {
+	if parameters.AppType == provideTypes.FrontProxy {
+		return session.deployFrontProxy(image, parameters.EnvironmentName)
+	}
+	return session.deployApp(appName, image, parameters.EnvironmentName, getAppManifest(parameters))
+}
+
+// getAppManifest returns the manifest the app is deployed with, nil for the front proxy
+func getAppManifest(parameters *provideTypes.DeployParameters) *manifest.Manifest {
+	if parameters.AppType == provideTypes.FrontProxy {
+		return nil
+	}
+	if parameters.Manifest == nil {
+		return manifest.Default()
+	}
+	return parameters.Manifest
+}
+
+// deployExistingImage deploys an image that was pushed before, e.g. by ci, without building it
+func (session *Session) deployExistingImage(parameters *provideTypes.DeployParameters, appName string) error {
+	log := logger.New()
+	defer log.LogDone()
+	workflow := session.beginWorkflow(deployWorkflow(appName), map[string]string{
+		"type":       string(parameters.AppType),
+		"image":      parameters.Image,
+		"tag":        parameters.Tag,
+		"domainName": parameters.DomainName,
+	})
+	imageOutputs, err := session.runStep(workflow, "existingImage", func(session *Session) (map[string]string, error) {
+		image, err := session.findExistingImage(appName, parameters.Image, parameters.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"image": image}, nil
+	})
+	if err != nil {
+		return err
+	}
+	image := imageOutputs["image"]
+	log.Result("image", image)
+	_, err = session.runStep(workflow, "app", func(session *Session) (map[string]string, error) {
+		return nil, session.deployImage(parameters, appName, image)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = session.runStep(workflow, "release", func(session *Session) (map[string]string, error) {
+		return nil, session.recordRelease(parameters.EnvironmentName, appName, image, "", getAppManifest(parameters))
+	})
+	if err != nil {
+		return err
+	}
+	session.finishWorkflow(workflow)
+	return nil
+}
+
+// getContextFingerprint returns the fingerprint of the build context, or a new id when it cannot be read so that
+// the deploy starts over
+func getContextFingerprint(path string, dockerfile string) string {
+	fingerprint, err := docker.FingerprintBuildContext(path, dockerfile)
+	if err != nil {
+		log := logger.New()
+		defer log.LogDone()
+		log.Debugf("fail to fingerprint the build context, %v", err)
+		return generateID()
+	}
+	return fingerprint
+}
+
+// Deploy builds, publish image, deploy service and record the release, steps completed by an interrupted run with the same inputs are skipped,
+// an image given with --image or --tag is deployed as is
+func (session *Session) Deploy(parameters *provideTypes.DeployParameters) error {
+	log := logger.New()
+	defer log.LogDone()
 	appType := parameters.AppType
 	appName := parameters.AppName
 	path := parameters.Path
@@ -334,25 +455,104 @@ func (session *Session) Deploy(parameters *provideTypes.DeployParameters) {
 	domainEmail := parameters.DomainEmail
 	envName := parameters.EnvironmentName
 
-	id := generateID()
+	buildOptions := parameters.Build
 	if appType == provideTypes.FrontProxy {
 		appName = string(cmdTypes.KnownAppFrontProxy)
-		frontProxyPath := getFrontProxyPath()
-		tag := buildImage(frontProxyPath, appName, id, map[string]*string{
-			"DOMAIN_NAME":  &domainName,
-			"DOMAIN_EMAIL": &domainEmail,
-			"ENV_NAME":     &envName,
-		})
-		repository := session.createRepository(appName)
-		session.uploadImage(tag, repository, id)
-		imageId := fmt.Sprintf("%s:%s", *repository, id)
-		session.deployFrontProxy(imageId, envName)
-		return
+		buildOptions.Dockerfile = ""
+		buildOptions.Target = ""
+		buildOptions.BuildArgs = map[string]string{
+			"DOMAIN_NAME":  domainName,
+			"DOMAIN_EMAIL": domainEmail,
+			"ENV_NAME":     envName,
+		}
+	}
+	if parameters.Image != "" || parameters.Tag != "" {
+		return session.deployExistingImage(parameters, appName)
+	}
+	workflowPath := path
+	if absolutePath, err := filepath.Abs(path); err == nil {
+		workflowPath = absolutePath
 	}
+	// deploying with other build options, or after the files of the build context changed, starts over instead of resuming
+	build, _ := json.Marshal(buildOptions)
+	inputs := map[string]string{
+		"type":       string(appType),
+		"path":       workflowPath,
+		"domainName": domainName,
+		"build":      string(build),
+	}
+	if appType != provideTypes.FrontProxy {
+		inputs["gitSha"] = getGitSha(path)
+		inputs["context"] = getContextFingerprint(path, buildOptions.Dockerfile)
+	}
+	workflow := session.beginWorkflow(deployWorkflow(appName), inputs)
 
-	tag := buildImage(path, appName, id, map[string]*string{})
-	repository := session.createRepository(appName)
-	session.uploadImage(tag, repository, id)
-	imageId := fmt.Sprintf("%s:%s", *repository, id)
-	session.deployApp(appName, imageId, envName)
+	imageOutputs, err := session.runStep(workflow, "image", func(session *Session) (map[string]string, error) {
+		id := generateID()
+		if session.plan {
+			return map[string]string{"id": id}, nil
+		}
+		buildPath := path
+		if appType == provideTypes.FrontProxy {
+			frontProxyPath, cleanUp, err := materialiseFrontProxy()
+			if err != nil {
+				return nil, provideTypes.NewError("prepare front proxy files", err)
+			}
+			defer cleanUp()
+			buildPath = frontProxyPath
+		}
+		tag, err := docker.BuildImage(buildPath, appName, id, buildOptions)
+		if err != nil {
+			return nil, err
+		}
+		outputs := map[string]string{"id": id, "tag": *tag}
+		if appType != provideTypes.FrontProxy {
+			outputs["gitSha"] = getGitSha(path)
+		}
+		return outputs, nil
+	})
+	if err != nil {
+		return err
+	}
+	id := imageOutputs["id"]
+	repositoryOutputs, err := session.runStep(workflow, "repository", func(session *Session) (map[string]string, error) {
+		repository, err := session.createRepository(appName)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"repository": *repository}, nil
+	})
+	if err != nil {
+		return err
+	}
+	repository := repositoryOutputs["repository"]
+	imageId := fmt.Sprintf("%s:%s", repository, id)
+	_, err = session.runStep(workflow, "upload", func(session *Session) (map[string]string, error) {
+		var tag *string
+		if builtTag, built := imageOutputs["tag"]; built {
+			tag = &builtTag
+		}
+		if err := session.uploadImage(tag, &repository, id); err != nil {
+			return nil, err
+		}
+		return map[string]string{"image": imageId}, nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Result("image", imageId)
+	_, err = session.runStep(workflow, "app", func(session *Session) (map[string]string, error) {
+		return nil, session.deployImage(parameters, appName, imageId)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = session.runStep(workflow, "release", func(session *Session) (map[string]string, error) {
+		return nil, session.recordRelease(envName, appName, imageId, imageOutputs["gitSha"], getAppManifest(parameters))
+	})
+	if err != nil {
+		return err
+	}
+	session.finishWorkflow(workflow)
+	return nil
 }
@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/aws/errors"
+)
+
+const nestedStackResourceType = "AWS::CloudFormation::Stack"
+
+func isFailedEvent(event cloudformation.StackEvent) bool {
+	return strings.HasSuffix(string(event.ResourceStatus), "_FAILED")
+}
+
+// isCollateralFailure is true for resources that only failed because another resource did
+func isCollateralFailure(event cloudformation.StackEvent) bool {
+	reason := aws.StringValue(event.ResourceStatusReason)
+	return strings.Contains(reason, "cancelled") ||
+		strings.HasPrefix(reason, "The following resource(s) failed")
+}
+
+// firstFailure picks the earliest failed event that is not just a consequence of another failure
+func firstFailure(events []cloudformation.StackEvent) *cloudformation.StackEvent {
+	var fallback *cloudformation.StackEvent
+	// events are returned most recent first
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		if !isFailedEvent(event) {
+			continue
+		}
+		if !isCollateralFailure(event) {
+			return &event
+		}
+		if fallback == nil {
+			fallback = &event
+		}
+	}
+	return fallback
+}
+
+// operationStart returns the time of the earliest event of the resource that failed, events are most recent first
+func operationStart(events []cloudformation.StackEvent, failure cloudformation.StackEvent) time.Time {
+	for i := len(events) - 1; i >= 0; i-- {
+		if aws.StringValue(events[i].LogicalResourceId) == aws.StringValue(failure.LogicalResourceId) {
+			return *events[i].Timestamp
+		}
+	}
+	return *failure.Timestamp
+}
+
+// diagnose explains why the operation failed by finding the first failed resource, walking into nested stacks
+func (stack *Stack) diagnose(opToken string, err error) error {
+	log := logger.New()
+	defer log.LogDone()
+
+	events, eventsErr := stack.GetEvents(opToken)
+	if eventsErr != nil {
+		log.Debug(eventsErr.Error())
+		return &errors.StackOperationFailed{StackName: stack.name, Err: err}
+	}
+
+	stackName := stack.name
+	failure := firstFailure(events)
+	for failure != nil &&
+		aws.StringValue(failure.ResourceType) == nestedStackResourceType &&
+		aws.StringValue(failure.PhysicalResourceId) != aws.StringValue(failure.StackId) {
+		nestedStack := &Stack{api: stack.api, name: aws.StringValue(failure.PhysicalResourceId), ctx: stack.ctx}
+		// the nested operation runs between the first event of its resource and the failure of it
+		start, since := operationStart(events, *failure), *failure.Timestamp
+		nestedEvents, nestedErr := nestedStack.getEvents(func(event cloudformation.StackEvent) bool {
+			return !event.Timestamp.Before(start) && !event.Timestamp.After(since)
+		}, func(event cloudformation.StackEvent) bool {
+			return event.Timestamp.Before(start)
+		})
+		nestedFailure := firstFailure(nestedEvents)
+		if nestedErr != nil || nestedFailure == nil {
+			break
+		}
+		log.Debugf("following failure into nested stack %s", nestedStack.name)
+		stackName = aws.StringValue(nestedFailure.StackName)
+		events, failure = nestedEvents, nestedFailure
+	}
+
+	if failure == nil {
+		return &errors.StackOperationFailed{StackName: stack.name, Err: err}
+	}
+	return &errors.StackOperationFailed{
+		StackName:         stackName,
+		LogicalResourceID: aws.StringValue(failure.LogicalResourceId),
+		ResourceType:      aws.StringValue(failure.ResourceType),
+		Status:            string(failure.ResourceStatus),
+		Reason:            aws.StringValue(failure.ResourceStatusReason),
+		Err:               err,
+	}
+}
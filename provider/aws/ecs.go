@@ -2,8 +2,8 @@ package aws
 
 import (
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
-	"github.com/kahgeh/devenv/utils/ctx"
 )
 
 func (session *Session) DescribeService(serviceArn string, clusterName string) (*ecs.DescribeServicesResponse, error) {
@@ -16,9 +16,58 @@ func (session *Session) DescribeService(serviceArn string, clusterName string) (
 	}
 
 	req := svc.DescribeServicesRequest(input)
-	result, err := req.Send(ctx.GetContext())
+	result, err := req.Send(session.getContext())
 	if err != nil {
 		return nil, err
 	}
 	return result, nil
 }
+
+// getServiceInstanceIDs returns the instances running the tasks of the service, nil when the service does not exist
+func (session *Session) getServiceInstanceIDs(serviceName string, clusterName string) ([]string, error) {
+	svc := ecs.New(session.config)
+	listResponse, err := svc.ListTasksRequest(&ecs.ListTasksInput{
+		Cluster:       aws.String(clusterName),
+		ServiceName:   aws.String(serviceName),
+		DesiredStatus: ecs.DesiredStatusRunning,
+	}).Send(session.getContext())
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == ecs.ErrCodeServiceNotFoundException {
+			return nil, nil
+		}
+		return nil, err
+	}
+	instanceIDs := []string{}
+	if len(listResponse.TaskArns) == 0 {
+		return instanceIDs, nil
+	}
+
+	tasksResponse, err := svc.DescribeTasksRequest(&ecs.DescribeTasksInput{
+		Cluster: aws.String(clusterName),
+		Tasks:   listResponse.TaskArns,
+	}).Send(session.getContext())
+	if err != nil {
+		return nil, err
+	}
+	var containerInstanceArns []string
+	for _, task := range tasksResponse.Tasks {
+		if task.ContainerInstanceArn != nil && aws.StringValue(task.LastStatus) == "RUNNING" {
+			containerInstanceArns = append(containerInstanceArns, *task.ContainerInstanceArn)
+		}
+	}
+	if len(containerInstanceArns) == 0 {
+		return instanceIDs, nil
+	}
+
+	instancesResponse, err := svc.DescribeContainerInstancesRequest(&ecs.DescribeContainerInstancesInput{
+		Cluster:            aws.String(clusterName),
+		ContainerInstances: containerInstanceArns,
+	}).Send(session.getContext())
+	if err != nil {
+		return nil, err
+	}
+	for _, containerInstance := range instancesResponse.ContainerInstances {
+		instanceIDs = append(instanceIDs, aws.StringValue(containerInstance.Ec2InstanceId))
+	}
+	return instanceIDs, nil
+}
@@ -0,0 +1,33 @@
+package errors
+
+import (
+	"fmt"
+
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// StackOperationFailed names the resource that made a stack operation fail and the reason aws gave
+type StackOperationFailed struct {
+	StackName         string
+	LogicalResourceID string
+	ResourceType      string
+	Status            string
+	Reason            string
+	Err               error
+}
+
+func (e *StackOperationFailed) Error() string {
+	if e.LogicalResourceID == "" {
+		return fmt.Sprintf("stack %s failed, %v", e.StackName, e.Err)
+	}
+	return fmt.Sprintf("%s (%s) of stack %s is %s, %s",
+		e.LogicalResourceID, e.ResourceType, e.StackName, e.Status, e.Reason)
+}
+
+func (e *StackOperationFailed) Unwrap() error {
+	return e.Err
+}
+
+func (e *StackOperationFailed) Kind() types.ErrorKind {
+	return types.AwsErrorKind
+}
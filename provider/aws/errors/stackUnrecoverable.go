@@ -0,0 +1,22 @@
+package errors
+
+import (
+	"fmt"
+
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// StackUnrecoverable is returned when a stack is in a state that only deleting it can get out of
+type StackUnrecoverable struct {
+	StackName string
+	Status    string
+}
+
+func (e *StackUnrecoverable) Error() string {
+	return fmt.Sprintf("stack %s is %s and can no longer be updated, rerun with --auto-recover to delete and create it again",
+		e.StackName, e.Status)
+}
+
+func (e *StackUnrecoverable) Kind() types.ErrorKind {
+	return types.UserErrorKind
+}
@@ -0,0 +1,96 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/kahgeh/devenv/logger"
+)
+
+// EventStreamer polls the events of a stack operation while it runs and reports each event once
+type EventStreamer struct {
+	log     *logger.Logger
+	stack   *Stack
+	opToken string
+	seen    map[string]bool
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+func describeEvent(event cloudformation.StackEvent) string {
+	description := fmt.Sprintf("%s %s", aws.StringValue(event.LogicalResourceId), event.ResourceStatus)
+	if reason := aws.StringValue(event.ResourceStatusReason); reason != "" {
+		description = fmt.Sprintf("%s: %s", description, reason)
+	}
+	return description
+}
+
+// StreamEvents starts reporting the events of the operation identified by the client request token,
+// call Stop on the returned streamer once the operation completes
+func (stack *Stack) StreamEvents(opToken *string, log *logger.Logger) *EventStreamer {
+	streamer := &EventStreamer{
+		log:     log,
+		stack:   stack,
+		seen:    map[string]bool{},
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if opToken == nil {
+		close(streamer.stopped)
+		return streamer
+	}
+	streamer.opToken = *opToken
+	go streamer.run()
+	return streamer
+}
+
+func (streamer *EventStreamer) run() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer func() {
+		ticker.Stop()
+		close(streamer.stopped)
+	}()
+	for {
+		select {
+		case <-streamer.stack.ctx.Done():
+			return
+		case <-streamer.stop:
+			streamer.report()
+			return
+		case <-ticker.C:
+			streamer.report()
+		}
+	}
+}
+
+func (streamer *EventStreamer) report() {
+	log := streamer.log
+	events, err := streamer.stack.GetEvents(streamer.opToken)
+	if err != nil {
+		log.Debug(err.Error())
+		return
+	}
+	// events are returned most recent first
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		eventID := aws.StringValue(event.EventId)
+		if streamer.seen[eventID] {
+			continue
+		}
+		streamer.seen[eventID] = true
+		log.Progressf("%s", describeEvent(event))
+	}
+}
+
+// Stop reports the remaining events and waits for the streamer to finish
+func (streamer *EventStreamer) Stop() {
+	select {
+	case <-streamer.stopped:
+		return
+	default:
+	}
+	close(streamer.stop)
+	<-streamer.stopped
+}
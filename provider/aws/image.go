@@ -0,0 +1,101 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// ecrImagePattern matches <account>.dkr.ecr.<region>.amazonaws.com/<repository>[:<tag>][@<digest>]
+var ecrImagePattern = regexp.MustCompile(
+	`^(\d{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?/([a-z0-9._/-]+)(?::([A-Za-z0-9_.-]+))?(?:@(sha256:[a-f0-9]{64}))?$`)
+
+type ecrImage struct {
+	registryID     string
+	region         string
+	repositoryName string
+	imageID        ecr.ImageIdentifier
+}
+
+// parseEcrImage splits the uri of an image in ecr, an image without a tag or digest is the latest one
+func parseEcrImage(image string) (*ecrImage, error) {
+	matches := ecrImagePattern.FindStringSubmatch(image)
+	if matches == nil {
+		return nil, fmt.Errorf("%q is not an ecr image uri, "+
+			"expected <account>.dkr.ecr.<region>.amazonaws.com/<repository>:<tag> or @<digest>", image)
+	}
+	parsed := &ecrImage{registryID: matches[1], region: matches[2], repositoryName: matches[3]}
+	switch {
+	case matches[5] != "":
+		parsed.imageID.ImageDigest = aws.String(matches[5])
+	case matches[4] != "":
+		parsed.imageID.ImageTag = aws.String(matches[4])
+	default:
+		parsed.imageID.ImageTag = aws.String("latest")
+	}
+	return parsed, nil
+}
+
+// getRepositoryURI returns the uri of the repository created by an earlier deploy of the app
+func (session *Session) getRepositoryURI(appName string) (*string, error) {
+	stackName := session.GetComputeConfig().GetEcrStackName(appName)
+	description, err := NewStack(stackName, session).Describe()
+	if err != nil {
+		return nil, types.NewAwsError("get repository details", err)
+	}
+	if description == nil || len(description.Outputs) == 0 {
+		return nil, types.NewUserError("get repository details",
+			fmt.Errorf("%s has no repository, deploy it without --tag first", appName))
+	}
+	return description.Outputs[0].OutputValue, nil
+}
+
+// findExistingImage returns the image given with --image, or the tag of the app repository given with --tag,
+// once ecr confirms it exists so that the service does not fail to pull it
+func (session *Session) findExistingImage(appName string, image string, tag string) (string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	if tag != "" {
+		repository, err := session.getRepositoryURI(appName)
+		if err != nil {
+			log.Fail(err)
+			return "", err
+		}
+		image = fmt.Sprintf("%s:%s", *repository, tag)
+	}
+	parsed, err := parseEcrImage(image)
+	if err != nil {
+		err = types.NewUserError("read image", err)
+		log.Fail(err)
+		return "", err
+	}
+
+	config := session.config.Copy()
+	config.Region = parsed.region
+	request := ecr.New(config).DescribeImagesRequest(&ecr.DescribeImagesInput{
+		RegistryId:     aws.String(parsed.registryID),
+		RepositoryName: aws.String(parsed.repositoryName),
+		ImageIds:       []ecr.ImageIdentifier{parsed.imageID},
+	})
+	response, err := request.Send(session.getContext())
+	if err != nil {
+		if awsErr, ok := err.(awserr.Error); ok &&
+			(awsErr.Code() == ecr.ErrCodeImageNotFoundException || awsErr.Code() == ecr.ErrCodeRepositoryNotFoundException) {
+			err = types.NewUserError(fmt.Sprintf("find image %s", image), fmt.Errorf("%s", awsErr.Message()))
+		} else {
+			err = types.NewAwsError(fmt.Sprintf("find image %s", image), err)
+		}
+		log.Fail(err)
+		return "", err
+	}
+	if len(response.ImageDetails) > 0 {
+		log.Debugf("image digest=%s", aws.StringValue(response.ImageDetails[0].ImageDigest))
+	}
+	log.Succeedf("Found %s", image)
+	return image, nil
+}
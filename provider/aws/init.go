@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/service/kms"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
@@ -12,10 +13,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
-	"github.com/kahgeh/devenv/fixed"
 	"github.com/kahgeh/devenv/logger"
 	"github.com/kahgeh/devenv/utils"
-	"github.com/kahgeh/devenv/utils/ctx"
+	"github.com/kahgeh/devenv/utils/graph"
 	"github.com/mitchellh/go-homedir"
 )
 
@@ -28,53 +28,60 @@ func getSshFolderPath() string {
 	return fmt.Sprintf("%v/.ssh", home)
 }
 
-func getFrontProxyPath() string {
-	configFolderPath := fixed.GetConfigFolderPath()
-	return fmt.Sprintf("%s/aws/%s", configFolderPath, "front-proxy")
-}
-
-func getCfnTemplateContent(cfnFileName string) (string, error) {
-	configFolderPath := fixed.GetConfigFolderPath()
-	cfnTemplatePath := fmt.Sprintf("%s/aws/%s", configFolderPath, cfnFileName)
-	content, err := ioutil.ReadFile(cfnTemplatePath)
+// getCfnTemplateContent reads the template, templates with data, e.g. app.yml, are rendered with text/template first
+func getCfnTemplateContent(cfnFileName string, data interface{}) (string, error) {
+	content, err := readTemplateFile(cfnFileName)
 	if err != nil {
 		return "", err
 	}
+	if data != nil {
+		content, err = renderTemplate(cfnFileName, content, data)
+		if err != nil {
+			return "", err
+		}
+	}
 	limit := 51200
 	if len(content) > limit {
-		return "", fmt.Errorf("template %s is over the %v limit", cfnTemplatePath, limit)
+		return "", fmt.Errorf("template %s is over the %v limit", cfnFileName, limit)
 	}
 	return string(content), nil
 }
 
-func (session *Session) createKeyPair() {
+func (session *Session) createKeyPair() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 
 	keyPairName := session.GetKeyPairName()
+	if session.skipWhenPlanning(log, fmt.Sprintf("create key pair %q if it does not exist", keyPairName)) {
+		return nil
+	}
 	request := ec2.New(session.config).CreateKeyPairRequest(&ec2.CreateKeyPairInput{
 		KeyName: aws.String(keyPairName),
 	})
-	result, err := request.Send(ctx.GetContext())
+	result, err := request.Send(session.getContext())
 
 	sshFolderPath := getSshFolderPath()
 	sshPrivateKeyFilePath := fmt.Sprintf("%s/%s.pem", sshFolderPath, keyPairName)
 	if aerr, ok := err.(awserr.Error); ok {
 		if aerr.Code() == "InvalidKeyPair.Duplicate" {
 			if _, err := os.Stat(sshPrivateKeyFilePath); os.IsNotExist(err) {
-				log.Failf("Key pair %q already exists, but '.pem' does not exist", keyPairName, sshPrivateKeyFilePath)
-				return
+				err = types.NewUserError("create key pair",
+					fmt.Errorf("key pair %q already exists, but %q does not exist", keyPairName, sshPrivateKeyFilePath))
+				log.Fail(err)
+				return err
 			}
 			log.Succeedf("Key pair %q already exists", keyPairName)
-			return
+			return nil
 		}
 		log.Debug(aerr.Message())
-		log.Fail(aerr.Code())
-		return
+		err = types.NewAwsError("create key pair", err)
+		log.Fail(err)
+		return err
 	}
-	if result == nil {
-		log.Fail("no response from creating key pair request")
-		return
+	if err != nil {
+		err = types.NewAwsError("create key pair", err)
+		log.Fail(err)
+		return err
 	}
 	keyPair := result
 	log.Debugf("Created key pair %q %s\n%s\n",
@@ -84,54 +91,59 @@ func (session *Session) createKeyPair() {
 	log.Info("saving private key to ssh folder...")
 	err = utils.CreateFolderIfNotExist(sshFolderPath)
 	if err != nil {
-		log.Failf("fail to ensure %q exist", sshFolderPath)
-		return
+		err = types.NewError(fmt.Sprintf("ensure %q exist", sshFolderPath), err)
+		log.Fail(err)
+		return err
 	}
 
 	err = ioutil.WriteFile(sshPrivateKeyFilePath, []byte(*keyPair.KeyMaterial), 0600)
 	if err != nil {
-		log.Failf("Failed saving private key to %s", sshPrivateKeyFilePath)
-		return
+		err = types.NewError(fmt.Sprintf("save private key to %s", sshPrivateKeyFilePath), err)
+		log.Fail(err)
+		return err
 	}
+	log.Result("privateKey", sshPrivateKeyFilePath)
 	log.Succeedf("Saved private key to %s", sshPrivateKeyFilePath)
+	return nil
 }
 
-func (session *Session) createVpc() {
+func (session *Session) createVpc() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 
 	config := session.GetComputeConfig()
 	stackName := config.VpcStackName
 
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
-	err := stack.Create("vpc.yml", []cloudformation.Parameter{})
+	stack := NewStack(stackName, session)
+	err := stack.Create("vpc.yml", []cloudformation.Parameter{
+		{
+			ParameterKey:   aws.String("ExportPrefix"),
+			ParameterValue: aws.String(config.VpcExportPrefix),
+		},
+	})
 	if err != nil {
-		log.Fail("fail to create vpc")
+		err = types.NewAwsError("create vpc", err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) createEcsSpotFleet(envName string, domainName string) {
-	log := logger.NewTaskLogger()
-	defer log.LogDone()
-
+func (session *Session) getSpotFleetParameters(envName string, domainName string, fleet types.FleetParameters) []cloudformation.Parameter {
 	config := session.GetComputeConfig()
-	stackName := config.EcsSpotFleetStackName
-	clusterName := config.EcsClusterName
-	keyPairName := config.KeyPairName
-	purpose := config.EcsSpotFleetPurpose
-
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
-	err := stack.Create("spotFleet.yml", []cloudformation.Parameter{
+	parameters := []cloudformation.Parameter{
+		{
+			ParameterKey:   aws.String("VpcExportPrefix"),
+			ParameterValue: aws.String(config.VpcExportPrefix),
+		},
 		{
 			ParameterKey:   aws.String("EcsClusterName"),
-			ParameterValue: aws.String(clusterName),
+			ParameterValue: aws.String(config.EcsClusterName),
 		},
 		{
 			ParameterKey:   aws.String("KeyName"),
-			ParameterValue: aws.String(keyPairName),
+			ParameterValue: aws.String(config.KeyPairName),
 		},
 		{
 			ParameterKey:   aws.String("Environment"),
@@ -139,20 +151,47 @@ func (session *Session) createEcsSpotFleet(envName string, domainName string) {
 		},
 		{
 			ParameterKey:   aws.String("FleetType"),
-			ParameterValue: aws.String(purpose),
+			ParameterValue: aws.String(config.EcsSpotFleetPurpose),
 		},
 		{
 			ParameterKey:   aws.String("DomainName"),
 			ParameterValue: aws.String(domainName),
 		},
-	})
+	}
+	// the target capacity is left to the template, start and stop change it on the spot fleet request
+	if fleet.InstanceType != "" {
+		parameters = append(parameters, cloudformation.Parameter{
+			ParameterKey:   aws.String("InstanceType"),
+			ParameterValue: aws.String(fleet.InstanceType),
+		})
+	}
+	if fleet.SpotPrice != "" {
+		parameters = append(parameters, cloudformation.Parameter{
+			ParameterKey:   aws.String("SpotPrice"),
+			ParameterValue: aws.String(fleet.SpotPrice),
+		})
+	}
+	return parameters
+}
+
+// createEcsSpotFleet creates the spot fleet or updates its instance type and spot price when they changed
+func (session *Session) createEcsSpotFleet(envName string, domainName string, fleet types.FleetParameters) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	stackName := session.GetComputeConfig().EcsSpotFleetStackName
+	stack := NewStack(stackName, session)
+	err := stack.Update("spotFleet.yml", session.getSpotFleetParameters(envName, domainName, fleet))
 	if err != nil {
-		log.Fail("fail to create spot fleet")
+		err = types.NewAwsError("create spot fleet", err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) createEcsCluster(envName string) {
+func (session *Session) createEcsCluster(envName string) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 
@@ -160,8 +199,7 @@ func (session *Session) createEcsCluster(envName string) {
 	stackName := config.EcsClusterStackName
 	clusterName := config.EcsClusterName
 
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
+	stack := NewStack(stackName, session)
 	err := stack.Create("ecsCluster.yml", []cloudformation.Parameter{
 		{
 			ParameterKey:   aws.String("ClusterName"),
@@ -173,25 +211,32 @@ func (session *Session) createEcsCluster(envName string) {
 		},
 	})
 	if err != nil {
-		log.Fail("fail to create ecs cluster")
+		err = types.NewAwsError("create ecs cluster", err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) savePstoreKey(keyName string, path string) {
+func (session *Session) savePstoreKey(keyName string, path string) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 
+	if session.skipWhenPlanning(log, fmt.Sprintf("save %q key arn to %q", keyName, path)) {
+		return nil
+	}
+
 	apiKms := kms.New(session.config)
 	describeKeyRequest := apiKms.DescribeKeyRequest(&kms.DescribeKeyInput{
 		KeyId: aws.String(keyName),
 	})
 
-	keyDescription, err := describeKeyRequest.Send(ctx.GetContext())
+	keyDescription, err := describeKeyRequest.Send(session.getContext())
 	if err != nil {
-		log.Debug(err.Error())
-		log.Failf("fail to get %q key arn", keyName)
-		return
+		err = types.NewAwsError(fmt.Sprintf("get %q key arn", keyName), err)
+		log.Fail(err)
+		return err
 	}
 
 	keyArn := keyDescription.DescribeKeyOutput.KeyMetadata.Arn
@@ -204,47 +249,93 @@ func (session *Session) savePstoreKey(keyName string, path string) {
 		Overwrite: aws.Bool(true),
 	})
 
-	putParamResponse, err := putParamRequest.Send(ctx.GetContext())
+	putParamResponse, err := putParamRequest.Send(session.getContext())
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("fail to save aws/ssm key arn to parameter store")
-		return
+		err = types.NewAwsError("save aws/ssm key arn to parameter store", err)
+		log.Fail(err)
+		return err
 	}
 
 	log.Debug(putParamResponse.PutParameterOutput.String())
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) saveWhaleDiscoveryVersion(envName, version string) {
+func (session *Session) saveWhaleDiscoveryVersion(envName, version string) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	ssmSession := session.NewSsmSession()
 	parameterName := fmt.Sprintf("/allEnvs/%s/WhaleDiscoVersion", envName)
+	if session.skipWhenPlanning(log, fmt.Sprintf("save discovery service version %q to %q", version, parameterName)) {
+		return nil
+	}
 	parameterVersion, err := ssmSession.SaveParameter(parameterName, version)
 	if err != nil {
-		log.Failf("cannot save discovery service version, %s", err.Error())
-		return
+		err = types.NewAwsError("save discovery service version", err)
+		log.Fail(err)
+		return err
 	}
 	if parameterVersion == nil {
-		log.Failf("cannot save discovery service version, the parameter version returned was empty")
-		return
+		err = types.NewAwsError("save discovery service version", fmt.Errorf("the parameter version returned was empty"))
+		log.Fail(err)
+		return err
 	}
 	log.Infof("discovery service version saved in param store %q for the %v time", parameterName, *parameterVersion)
 	log.Succeed()
-
+	return nil
 }
 
-func (session *Session) Initialise(parameters *types.InitialisationParameters) {
+// Initialise creates the key pair, vpc, ecs cluster and spot fleet of the environment,
+// steps that do not depend on each other run concurrently, steps completed by an interrupted run are skipped
+func (session *Session) Initialise(parameters *types.InitialisationParameters) error {
 	log := logger.New()
 	defer log.LogDone()
 	envName := parameters.EnvironmentName
 	domainName := parameters.DomainName
 	discoveryServiceVersion := parameters.DiscoveryServiceVersion
-	session.createKeyPair()
-	session.createVpc()
-	session.createEcsCluster(envName)
-	session.createEcsSpotFleet(envName, domainName)
 	pstoreKeyPath := fmt.Sprintf(string(TemplateParamStoreKeyPath), envName)
-	session.savePstoreKey("alias/aws/ssm", pstoreKeyPath)
-	session.saveWhaleDiscoveryVersion(envName, discoveryServiceVersion)
+	workflow := session.beginWorkflow(initWorkflow, map[string]string{
+		"domainName":              domainName,
+		"discoveryServiceVersion": discoveryServiceVersion,
+		"instanceType":            parameters.Fleet.InstanceType,
+		"spotPrice":               parameters.Fleet.SpotPrice,
+	})
+	step := func(name string, run func(session *Session) error) graph.Task {
+		return graph.Task{
+			Name: name,
+			Run: func(ctx context.Context) error {
+				_, err := session.WithContext(ctx).runStep(workflow, name, func(session *Session) (map[string]string, error) {
+					return nil, run(session)
+				})
+				return err
+			},
+		}
+	}
+	spotFleet := step("ecsSpotFleet", func(session *Session) error {
+		return session.createEcsSpotFleet(envName, domainName, parameters.Fleet)
+	})
+	spotFleet.DependsOn = []string{"keyPair", "vpc", "ecsCluster"}
+	err := graph.Run(session.getContext(), []graph.Task{
+		step("keyPair", func(session *Session) error {
+			return session.createKeyPair()
+		}),
+		step("vpc", func(session *Session) error {
+			return session.createVpc()
+		}),
+		step("ecsCluster", func(session *Session) error {
+			return session.createEcsCluster(envName)
+		}),
+		spotFleet,
+		step("pstoreKey", func(session *Session) error {
+			return session.savePstoreKey("alias/aws/ssm", pstoreKeyPath)
+		}),
+		step("whaleDiscoveryVersion", func(session *Session) error {
+			return session.saveWhaleDiscoveryVersion(envName, discoveryServiceVersion)
+		}),
+	})
+	if err != nil {
+		return err
+	}
+	session.finishWorkflow(workflow)
+	return nil
 }
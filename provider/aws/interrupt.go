@@ -0,0 +1,211 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// interruptCallTimeout bounds the aws calls made after the context of the session is about to be cancelled
+const interruptCallTimeout = time.Minute
+
+// errInterrupted stops the stack operations that would start after the interrupt handler took the ones in flight
+var errInterrupted = errors.New("the command was interrupted")
+
+// stackOperation is a change set created by the cli, it is tracked till the stack completes it
+// so that an interruption does not leave it running or pending in aws
+type stackOperation struct {
+	stack         *Stack
+	changeSet     *ChangeSet
+	changesetType cloudformation.ChangeSetType
+	// action describes an operation started without a change set, e.g. being deleted, it cannot be cancelled
+	action    string
+	executing bool
+}
+
+var inFlight = struct {
+	mutex       sync.Mutex
+	operations  map[*stackOperation]bool
+	interrupted bool
+}{operations: map[*stackOperation]bool{}}
+
+// trackChangeSet registers the change set till the returned operation is done, a change set created
+// once the interrupt handler took the operations in flight is deleted straight away
+func trackChangeSet(stack *Stack, changeSet *ChangeSet, changesetType cloudformation.ChangeSetType) (*stackOperation, error) {
+	operation := &stackOperation{stack: stack, changeSet: changeSet, changesetType: changesetType}
+	if !operation.track() {
+		_ = deletePendingChangeSet(operation)
+		return nil, errInterrupted
+	}
+	return operation, nil
+}
+
+// trackStackAction registers an operation started without a change set, e.g. a delete or a continued rollback,
+// till the returned operation is done so that an interruption reports it still runs in aws
+func trackStackAction(stack *Stack, action string) (*stackOperation, error) {
+	operation := &stackOperation{stack: stack, action: action}
+	if !operation.track() {
+		return nil, errInterrupted
+	}
+	return operation, nil
+}
+
+// track adds the operation to the ones in flight, false once the interrupt handler took them
+func (operation *stackOperation) track() bool {
+	inFlight.mutex.Lock()
+	defer inFlight.mutex.Unlock()
+	if inFlight.interrupted {
+		return false
+	}
+	inFlight.operations[operation] = true
+	return true
+}
+
+// execute marks the operation as executing and sends its request, the interrupt handler waits
+// for the request so it finds the change set either pending or executing
+func (operation *stackOperation) execute(send func() (*string, error)) (*string, error) {
+	inFlight.mutex.Lock()
+	defer inFlight.mutex.Unlock()
+	if inFlight.interrupted {
+		// the handler took the change set as pending and deletes it
+		return nil, errInterrupted
+	}
+	operation.executing = true
+	return send()
+}
+
+func (operation *stackOperation) done() {
+	inFlight.mutex.Lock()
+	defer inFlight.mutex.Unlock()
+	delete(inFlight.operations, operation)
+}
+
+// takeOperations returns the operations in flight, they are no longer tracked and no operation starts after it
+func takeOperations() []*stackOperation {
+	inFlight.mutex.Lock()
+	defer inFlight.mutex.Unlock()
+	inFlight.interrupted = true
+	var operations []*stackOperation
+	for operation := range inFlight.operations {
+		operations = append(operations, operation)
+	}
+	inFlight.operations = map[*stackOperation]bool{}
+	return operations
+}
+
+// confirmInterruptAction asks before acting on an interruption from the keyboard,
+// SIGTERM, e.g. a cancelled CI job, and scripts cannot answer so the action is taken
+func confirmInterruptAction(received os.Signal, question string) bool {
+	if received == syscall.SIGTERM || !logger.CanPrompt() {
+		return true
+	}
+	confirmed, err := logger.Confirm(question)
+	return err == nil && confirmed
+}
+
+func deletePendingChangeSet(operation *stackOperation) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	callCtx, cancel := context.WithTimeout(context.Background(), interruptCallTimeout)
+	defer cancel()
+	request := operation.stack.api.DeleteChangeSetRequest(&cloudformation.DeleteChangeSetInput{
+		ChangeSetName: aws.String(operation.changeSet.id),
+	})
+	if _, err := request.Send(callCtx); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("delete change set %s", operation.changeSet.name), err)
+		log.Fail(err)
+		return err
+	}
+	if operation.changesetType == cloudformation.ChangeSetTypeCreate {
+		// a create change set leaves an empty stack in REVIEW_IN_PROGRESS behind
+		deleteRequest := operation.stack.api.DeleteStackRequest(&cloudformation.DeleteStackInput{
+			StackName: aws.String(operation.stack.name),
+		})
+		if _, err := deleteRequest.Send(callCtx); err != nil {
+			err = types.NewAwsError(fmt.Sprintf("delete stack %s", operation.stack.name), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeedf("Deleted change set %s of stack %s", operation.changeSet.name, operation.stack.name)
+	return nil
+}
+
+// reportStackAction tells which stack operation carries on in aws, deletes and continued rollbacks cannot be cancelled
+func reportStackAction(operation *stackOperation) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	log.Skipf("Stack %s is still %s, it carries on in the background", operation.stack.name, operation.action)
+}
+
+func (session *Session) cancelStackUpdate(received os.Signal, operation *stackOperation) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	stackName := operation.stack.name
+	if !confirmInterruptAction(received, fmt.Sprintf("Stack %s is still updating, cancel the update and roll it back?", stackName)) {
+		log.Skipf("Stack %s carries on updating", stackName)
+		return nil
+	}
+	callCtx, cancel := context.WithTimeout(context.Background(), interruptCallTimeout)
+	defer cancel()
+	request := operation.stack.api.CancelUpdateStackRequest(&cloudformation.CancelUpdateStackInput{
+		StackName: aws.String(stackName),
+	})
+	if _, err := request.Send(callCtx); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("cancel the update of %s", stackName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeedf("Cancelled the update of %s, it rolls back in the background", stackName)
+	return nil
+}
+
+func (session *Session) deleteHalfCreatedStack(received os.Signal, operation *stackOperation) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	stackName := operation.stack.name
+	if !confirmInterruptAction(received, fmt.Sprintf("Stack %s is still being created, delete it?", stackName)) {
+		log.Skipf("Stack %s carries on being created", stackName)
+		return nil
+	}
+	callCtx, cancel := context.WithTimeout(context.Background(), interruptCallTimeout)
+	defer cancel()
+	request := operation.stack.api.DeleteStackRequest(&cloudformation.DeleteStackInput{
+		StackName: aws.String(stackName),
+	})
+	if _, err := request.Send(callCtx); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("delete %s", stackName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeedf("Deleting %s in the background", stackName)
+	return nil
+}
+
+// handleInterrupt leaves no change set pending, lets the user cancel the stack operations still running
+// and reports the ones that cannot be cancelled, the context of the session is cancelled once it returns
+func (session *Session) handleInterrupt(received os.Signal) {
+	for _, operation := range takeOperations() {
+		switch {
+		case operation.changeSet == nil:
+			if operation.executing {
+				reportStackAction(operation)
+			}
+		case !operation.executing:
+			_ = deletePendingChangeSet(operation)
+		case operation.changesetType == cloudformation.ChangeSetTypeCreate:
+			_ = session.deleteHalfCreatedStack(received, operation)
+		default:
+			_ = session.cancelStackUpdate(received, operation)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package aws
+
+import (
+	"fmt"
+
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/journal"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// workflowKey names the workflows of the journal
+type workflowKey string
+
+const (
+	initWorkflow  workflowKey = "init"
+	startWorkflow workflowKey = "start"
+)
+
+func deployWorkflow(appName string) workflowKey {
+	return workflowKey(fmt.Sprintf("deploy/%s", appName))
+}
+
+// beginWorkflow resumes the workflow when its previous run was interrupted, plans do not use the journal
+func (session *Session) beginWorkflow(key workflowKey, inputs map[string]string) *journal.Workflow {
+	if session.plan || session.journal == nil {
+		return nil
+	}
+	log := logger.New()
+	defer log.LogDone()
+	workflow, resumed, err := session.journal.Begin(string(key), inputs, session.fresh)
+	if err != nil {
+		log.Infof("fail to save %s, the next run will not be able to resume, %v", session.journal.Path(), err)
+	}
+	if resumed {
+		log.Print(fmt.Sprintf("resuming %s from %s, --fresh starts over\n", key, session.journal.Path()))
+	}
+	return workflow
+}
+
+// finishWorkflow marks the workflow as finished so that the next run starts over
+func (session *Session) finishWorkflow(workflow *journal.Workflow) {
+	if err := workflow.Finish(); err != nil {
+		log := logger.New()
+		defer log.LogDone()
+		log.Debugf("fail to save %s, %v", session.journal.Path(), err)
+	}
+}
+
+// forgetWorkflows removes workflows whose outputs are no longer valid, e.g. the instance recorded by start once stopped
+func (session *Session) forgetWorkflows(keys ...workflowKey) {
+	if session.plan || session.journal == nil {
+		return
+	}
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = string(key)
+	}
+	if err := session.journal.Forget(names...); err != nil {
+		log := logger.New()
+		defer log.LogDone()
+		log.Debugf("fail to save %s, %v", session.journal.Path(), err)
+	}
+}
+
+// removeJournal deletes the journal of an environment that was torn down
+func (session *Session) removeJournal() error {
+	if session.journal == nil {
+		return nil
+	}
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	if session.skipWhenPlanning(log, fmt.Sprintf("remove %s", session.journal.Path())) {
+		return nil
+	}
+	if err := session.journal.Remove(); err != nil {
+		err = types.NewError(fmt.Sprintf("remove %s", session.journal.Path()), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+func (session *Session) skipCompletedStep(step string) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	log.Skipf("%s completed by a previous run", step)
+}
+
+// runStep runs the step unless a previous run of the workflow completed it, in which case the recorded outputs are returned
+func (session *Session) runStep(workflow *journal.Workflow, step string, run func(session *Session) (map[string]string, error)) (map[string]string, error) {
+	if outputs, completed := workflow.Completed(step); completed {
+		session.skipCompletedStep(step)
+		return outputs, nil
+	}
+	stepSession := *session
+	stepSession.workflow = workflow
+	stepSession.step = step
+	outputs, err := run(&stepSession)
+	if err != nil {
+		return nil, err
+	}
+	if err := workflow.Complete(step, outputs); err != nil {
+		log := logger.New()
+		defer log.LogDone()
+		log.Debugf("fail to save %s, %v", session.journal.Path(), err)
+	}
+	return outputs, nil
+}
+
+// recordOutput saves an output of the running step before it completes, e.g. the change set it is executing
+func (session *Session) recordOutput(key string, value string) {
+	if err := session.workflow.Record(session.step, key, value); err != nil {
+		log := logger.New()
+		defer log.LogDone()
+		log.Debugf("fail to save %s, %v", session.journal.Path(), err)
+	}
+}
+
+// recordedOutput returns an output saved by an interrupted run of the running step
+func (session *Session) recordedOutput(key string) (string, bool) {
+	return session.workflow.Output(session.step, key)
+}
@@ -0,0 +1,138 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+const logsPollInterval = 2 * time.Second
+
+func toMilliseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func getAppLogGroupName(envName string, appName string) string {
+	return fmt.Sprintf("%s-%s", envName, appName)
+}
+
+func (session *Session) getLogGroupName(parameters *types.LogsParameters) (*string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	if !parameters.Instance {
+		log.Succeed()
+		return aws.String(getAppLogGroupName(parameters.EnvironmentName, parameters.AppName)), nil
+	}
+
+	config := session.GetComputeConfig()
+	logGroupName, err := session.getStackOutputValueByKey("CloudWatchLogsGroupName", config.EcsSpotFleetStackName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("find the log group of spot fleet stack %s", config.EcsSpotFleetStackName), err)
+		log.Fail(err)
+		return nil, err
+	}
+	log.Succeed()
+	return logGroupName, nil
+}
+
+// logCursor remembers where the previous poll stopped so that following does not repeat events
+type logCursor struct {
+	startTime int64
+	// seen holds the events at startTime, later events cannot have been reported yet
+	seen map[string]bool
+}
+
+func (cursor *logCursor) advance(event cloudwatchlogs.FilteredLogEvent) bool {
+	eventID := aws.StringValue(event.EventId)
+	timestamp := aws.Int64Value(event.Timestamp)
+	if timestamp < cursor.startTime || cursor.seen[eventID] {
+		return false
+	}
+	if timestamp > cursor.startTime {
+		cursor.startTime = timestamp
+		cursor.seen = map[string]bool{}
+	}
+	cursor.seen[eventID] = true
+	return true
+}
+
+func printLogEvent(log *logger.Logger, event cloudwatchlogs.FilteredLogEvent) {
+	timestamp := time.Unix(0, aws.Int64Value(event.Timestamp)*int64(time.Millisecond))
+	log.Print(fmt.Sprintf("%s %s %s\n",
+		timestamp.Format(time.RFC3339),
+		aws.StringValue(event.LogStreamName),
+		aws.StringValue(event.Message)))
+}
+
+// printLogEvents prints the events after the cursor across all streams of the group, ordered by time
+func (session *Session) printLogEvents(logGroupName *string, filter string, cursor *logCursor) error {
+	log := logger.New()
+	defer log.LogDone()
+	api := cloudwatchlogs.New(session.config)
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: logGroupName,
+		StartTime:    aws.Int64(cursor.startTime),
+		Interleaved:  aws.Bool(true),
+	}
+	if filter != "" {
+		input.FilterPattern = aws.String(filter)
+	}
+
+	var events []cloudwatchlogs.FilteredLogEvent
+	for {
+		response, err := api.FilterLogEventsRequest(input).Send(session.getContext())
+		if err != nil {
+			return err
+		}
+		events = append(events, response.Events...)
+		if response.NextToken == nil {
+			break
+		}
+		input.NextToken = response.NextToken
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return aws.Int64Value(events[i].Timestamp) < aws.Int64Value(events[j].Timestamp)
+	})
+	for _, event := range events {
+		if cursor.advance(event) {
+			printLogEvent(log, event)
+		}
+	}
+	return nil
+}
+
+// Logs prints the logs of an app, or of the environment instances, and keeps polling when following
+func (session *Session) Logs(parameters *types.LogsParameters) error {
+	log := logger.New()
+	defer log.LogDone()
+	logGroupName, err := session.getLogGroupName(parameters)
+	if err != nil {
+		return err
+	}
+
+	cursor := &logCursor{
+		startTime: toMilliseconds(time.Now().Add(-parameters.Since)),
+		seen:      map[string]bool{},
+	}
+	for {
+		if err := session.printLogEvents(logGroupName, parameters.Filter, cursor); err != nil {
+			err = types.NewAwsError(fmt.Sprintf("read logs of %s", *logGroupName), err)
+			log.Fail(err)
+			return err
+		}
+		if !parameters.Follow {
+			return nil
+		}
+		select {
+		case <-session.getContext().Done():
+			return nil
+		case <-time.After(logsPollInterval):
+		}
+	}
+}
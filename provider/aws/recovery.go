@@ -0,0 +1,134 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/aws/errors"
+)
+
+const settlePollInterval = 10 * time.Second
+
+// isStackInProgress is true while an operation is running against the stack
+func isStackInProgress(status cloudformation.StackStatus) bool {
+	return strings.HasSuffix(string(status), "_IN_PROGRESS") &&
+		status != cloudformation.StackStatusReviewInProgress
+}
+
+// isStackUnrecoverable is true when the stack can only be deleted, change sets are rejected,
+// a stack in REVIEW_IN_PROGRESS was never created and takes a create change set
+func isStackUnrecoverable(status cloudformation.StackStatus) bool {
+	switch status {
+	case cloudformation.StackStatusCreateFailed,
+		cloudformation.StackStatusRollbackFailed,
+		cloudformation.StackStatusRollbackComplete,
+		cloudformation.StackStatusDeleteFailed:
+		return true
+	}
+	return false
+}
+
+// confirmRecovery asks before deleting a stack that can no longer be updated,
+// runs that cannot prompt, e.g. scripts and CI jobs, delete it only with --auto-recover
+func confirmRecovery(stackName string, status cloudformation.StackStatus) bool {
+	if !logger.CanPrompt() {
+		return false
+	}
+	confirmed, err := logger.Confirm(fmt.Sprintf("Stack %s is %s and can no longer be updated, delete it and create it again?", stackName, status))
+	return err == nil && confirmed
+}
+
+// waitTillSettled waits for the operation running against the stack to finish
+func (stack *Stack) waitTillSettled(description *cloudformation.Stack) (*cloudformation.Stack, error) {
+	log := logger.New()
+	defer log.LogDone()
+	for description != nil && isStackInProgress(description.StackStatus) {
+		log.Infof("waiting for %s to leave %s...", stack.name, description.StackStatus)
+		select {
+		case <-stack.ctx.Done():
+			return nil, stack.ctx.Err()
+		case <-time.After(settlePollInterval):
+		}
+		var err error
+		description, err = stack.Describe()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return description, nil
+}
+
+// continueUpdateRollback resumes a rollback that failed, leaving the stack in UPDATE_ROLLBACK_COMPLETE
+func (stack *Stack) continueUpdateRollback() (*cloudformation.Stack, error) {
+	log := logger.New()
+	defer log.LogDone()
+	token := fmt.Sprintf("%s-%v", stack.name, time.Now().UnixNano())
+	operation, err := trackStackAction(stack, "rolling back")
+	if err != nil {
+		return nil, err
+	}
+	defer operation.done()
+	_, err = operation.execute(func() (*string, error) {
+		_, err := stack.api.ContinueUpdateRollbackRequest(&cloudformation.ContinueUpdateRollbackInput{
+			StackName:          aws.String(stack.name),
+			ClientRequestToken: aws.String(token),
+		}).Send(stack.ctx)
+		return &token, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	streamer := stack.StreamEvents(&token, log)
+	defer streamer.Stop()
+	description, err := stack.Describe()
+	if err == nil {
+		description, err = stack.waitTillSettled(description)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if description != nil && description.StackStatus != cloudformation.StackStatusUpdateRollbackComplete {
+		return nil, stack.diagnose(token, fmt.Errorf("continue update rollback ended in %s", description.StackStatus))
+	}
+	return description, nil
+}
+
+// recover brings the stack to a state where a change set can be applied,
+// it returns the stack description or nil when the stack had to be deleted
+func (stack *Stack) recover(description *cloudformation.Stack) (*cloudformation.Stack, error) {
+	log := logger.New()
+	defer log.LogDone()
+	description, err := stack.waitTillSettled(description)
+	if err != nil || description == nil {
+		return description, err
+	}
+
+	status := description.StackStatus
+	switch {
+	case status == cloudformation.StackStatusUpdateRollbackFailed:
+		if stack.planOnly {
+			log.Print(fmt.Sprintf("\nplan: continue the failed rollback of %s\n", stack.name))
+			return description, nil
+		}
+		log.Infof("continuing failed rollback of %s...", stack.name)
+		return stack.continueUpdateRollback()
+	case isStackUnrecoverable(status):
+		if stack.planOnly {
+			log.Print(fmt.Sprintf("\nplan: delete %s (%s) and create it again\n", stack.name, status))
+			return description, nil
+		}
+		if !stack.autoRecover && !confirmRecovery(stack.name, status) {
+			return nil, &errors.StackUnrecoverable{StackName: stack.name, Status: string(status)}
+		}
+		log.Infof("deleting %s, it is %s...", stack.name, status)
+		if _, err := stack.Delete(); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	return description, nil
+}
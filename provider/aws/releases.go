@@ -0,0 +1,237 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/manifest"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// maxReleases is how many releases of an app are kept, the oldest ones are deleted once a deploy goes over it
+const maxReleases = 25
+
+func getReleasesPath(envName string, appName string) string {
+	return fmt.Sprintf(string(TemplateAppReleasesPath), envName, appName)
+}
+
+// getReleaseParameterName returns the parameter of the release, parameter names cannot hold the colon of a digest
+func getReleaseParameterName(envName string, appName string, tag string) string {
+	return fmt.Sprintf("%s/%s", getReleasesPath(envName, appName), strings.ReplaceAll(tag, ":", "-"))
+}
+
+// getReleaseTag returns the tag, or the digest, the image is deployed by
+func getReleaseTag(image string) string {
+	parsed, err := parseEcrImage(image)
+	if err != nil {
+		return image[strings.LastIndex(image, ":")+1:]
+	}
+	if parsed.imageID.ImageDigest != nil {
+		return *parsed.imageID.ImageDigest
+	}
+	return *parsed.imageID.ImageTag
+}
+
+// getGitSha returns the commit checked out in the folder, empty when it is not a git repository
+func getGitSha(path string) string {
+	output, err := exec.Command("git", "-C", path, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// getDeployer returns the arn of the aws identity deploying, or the name of the local user when it cannot be read
+func (session *Session) getDeployer() string {
+	response, err := sts.New(session.config).GetCallerIdentityRequest(&sts.GetCallerIdentityInput{}).Send(session.getContext())
+	if err == nil {
+		return aws.StringValue(response.Arn)
+	}
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return ""
+}
+
+// getReleases returns the recorded releases of the app, newest first
+func (session *Session) getReleases(envName string, appName string) ([]types.Release, error) {
+	values, err := session.NewSsmSession().GetParameterValuesByPath(getReleasesPath(envName, appName))
+	if err != nil {
+		return nil, err
+	}
+	releases := make([]types.Release, 0, len(values))
+	for name, value := range values {
+		var release types.Release
+		if err := json.Unmarshal([]byte(value), &release); err != nil {
+			log := logger.New()
+			log.Debugf("skip release %s, %v", name, err)
+			log.LogDone()
+			continue
+		}
+		releases = append(releases, release)
+	}
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Timestamp.After(releases[j].Timestamp)
+	})
+	return releases, nil
+}
+
+// getCurrentImage returns the image the app stack runs, empty when the app is not deployed
+func (session *Session) getCurrentImage(appName string) (string, error) {
+	image, err := NewStack(session.GetComputeConfig().GetAppStackName(appName), session).GetParameterValue("Image")
+	return aws.StringValue(image), err
+}
+
+// recordRelease saves the deployed image and the manifest it runs with as the newest release of the app and deletes
+// the releases over maxReleases
+func (session *Session) recordRelease(envName string, appName string, image string, gitSha string,
+	appManifest *manifest.Manifest) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	tag := getReleaseTag(image)
+	name := getReleaseParameterName(envName, appName, tag)
+	if session.skipWhenPlanning(log, fmt.Sprintf("record release %q", name)) {
+		return nil
+	}
+	content, err := json.Marshal(types.Release{
+		Tag:       tag,
+		Image:     image,
+		Timestamp: time.Now().UTC(),
+		GitSha:    gitSha,
+		Deployer:  session.getDeployer(),
+		Manifest:  appManifest,
+	})
+	if err != nil {
+		err = types.NewError(fmt.Sprintf("record release %q", name), err)
+		log.Fail(err)
+		return err
+	}
+	ssmSession := session.NewSsmSession()
+	if _, err := ssmSession.SaveTieredParameter(name, string(content)); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("record release %q", name), err)
+		log.Fail(err)
+		return err
+	}
+	releases, err := session.getReleases(envName, appName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("list releases of %s", appName), err)
+		log.Fail(err)
+		return err
+	}
+	if len(releases) > maxReleases {
+		var names []string
+		for _, release := range releases[maxReleases:] {
+			names = append(names, getReleaseParameterName(envName, appName, release.Tag))
+		}
+		if err := ssmSession.DeleteParameters(names); err != nil {
+			err = types.NewAwsError(fmt.Sprintf("delete old releases of %s", appName), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Result("release", tag)
+	log.Succeedf("Recorded release %s of %s", tag, appName)
+	return nil
+}
+
+// Releases returns the recorded releases of the app newest first, the one the app runs is marked current
+func (session *Session) Releases(parameters *types.ReleasesParameters) ([]types.Release, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	releases, err := session.getReleases(parameters.EnvironmentName, parameters.AppName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("list releases of %s", parameters.AppName), err)
+		log.Fail(err)
+		return nil, err
+	}
+	image, err := session.getCurrentImage(parameters.AppName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("get %s details", parameters.AppName), err)
+		log.Fail(err)
+		return nil, err
+	}
+	for i := range releases {
+		releases[i].Current = image != "" && releases[i].Image == image
+	}
+	log.Succeed()
+	return releases, nil
+}
+
+// findRollbackRelease returns the release named by to, or the one deployed before the current release when to is empty
+func findRollbackRelease(appName string, releases []types.Release, to string) (*types.Release, error) {
+	if to != "" {
+		for i := range releases {
+			if releases[i].Tag == to {
+				return &releases[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%s has no release %s, see devenv releases %s", appName, to, appName)
+	}
+	for i := range releases {
+		if !releases[i].Current {
+			continue
+		}
+		if i+1 == len(releases) {
+			return nil, fmt.Errorf("%s runs its oldest release %s, there is nothing to roll back to", appName, releases[i].Tag)
+		}
+		return &releases[i+1], nil
+	}
+	return nil, fmt.Errorf("%s does not run a recorded release, pass --to <tag>", appName)
+}
+
+// Rollback deploys a recorded release of the app again without building it and with the manifest it was deployed
+// with, the rollback itself is not recorded so that the release before it stays the next one to go back to
+func (session *Session) Rollback(parameters *types.RollbackParameters) (*types.Release, error) {
+	log := logger.New()
+	defer log.LogDone()
+	releases, err := session.Releases(&types.ReleasesParameters{
+		EnvironmentName: parameters.EnvironmentName,
+		AppName:         parameters.AppName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	release, err := findRollbackRelease(parameters.AppName, releases, parameters.To)
+	if err != nil {
+		return nil, types.NewUserError(fmt.Sprintf("roll back %s", parameters.AppName), err)
+	}
+	if release.Current {
+		return nil, types.NewUserError(fmt.Sprintf("roll back %s", parameters.AppName),
+			fmt.Errorf("%s already runs release %s", parameters.AppName, release.Tag))
+	}
+	image, err := session.findExistingImage(parameters.AppName, release.Image, "")
+	if err != nil {
+		return nil, err
+	}
+	appManifest := release.Manifest
+	if appManifest == nil {
+		log.Infof("release %s was recorded without its manifest, deploying it with the one in the path", release.Tag)
+		appManifest = parameters.Manifest
+	}
+	deployParameters := &types.DeployParameters{
+		AppType:         types.Api,
+		AppName:         parameters.AppName,
+		EnvironmentName: parameters.EnvironmentName,
+		DomainName:      parameters.DomainName,
+		Manifest:        appManifest,
+		Image:           image,
+	}
+	if parameters.AppName == string(cmdTypes.KnownAppFrontProxy) {
+		deployParameters.AppType = types.FrontProxy
+	}
+	if err := session.deployImage(deployParameters, parameters.AppName, image); err != nil {
+		return nil, err
+	}
+	log.Result("image", image)
+	log.Result("release", release.Tag)
+	return release, nil
+}
@@ -0,0 +1,130 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+func getSecretsPath(envName string, appName string) string {
+	return fmt.Sprintf(string(TemplateAppSecretsPath), envName, appName)
+}
+
+func getSecretParameterName(envName string, appName string, key string) string {
+	return fmt.Sprintf("%s/%s", getSecretsPath(envName, appName), key)
+}
+
+func isParameterNotFound(err error) bool {
+	awsErr, ok := err.(awserr.Error)
+	return ok && awsErr.Code() == ssm.ErrCodeParameterNotFound
+}
+
+// getSecretParameterNames returns the parameter of each secret of the app by key
+func (session *Session) getSecretParameterNames(envName string, appName string) (map[string]string, error) {
+	path := getSecretsPath(envName, appName)
+	names, err := session.NewSsmSession().GetParameterNamesByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	secrets := map[string]string{}
+	for _, name := range names {
+		secrets[strings.TrimPrefix(name, path+"/")] = name
+	}
+	return secrets, nil
+}
+
+// SetSecret saves the secret as a SecureString encrypted with the aws/ssm key of the environment
+func (session *Session) SetSecret(parameters *types.SecretParameters) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	name := getSecretParameterName(parameters.EnvironmentName, parameters.AppName, parameters.Key)
+	if session.skipWhenPlanning(log, fmt.Sprintf("save secret %q", name)) {
+		return nil
+	}
+	ssmSession := session.NewSsmSession()
+	keyArn, err := ssmSession.GetParameterValue(fmt.Sprintf(string(TemplateParamStoreKeyPath), parameters.EnvironmentName))
+	if err != nil {
+		if isParameterNotFound(err) {
+			err = types.NewUserError("get the parameter store key",
+				fmt.Errorf("environment %s is not initialised, run init first", parameters.EnvironmentName))
+		} else {
+			err = types.NewAwsError("get the parameter store key", err)
+		}
+		log.Fail(err)
+		return err
+	}
+	version, err := ssmSession.SaveSecureParameter(name, parameters.Value, *keyArn)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("save secret %q", name), err)
+		log.Fail(err)
+		return err
+	}
+	log.Result("secret", name)
+	log.Result("version", *version)
+	log.Succeedf("Saved %s version %d, deploy %s to pass it to the app", name, *version, parameters.AppName)
+	return nil
+}
+
+// GetSecret returns the decrypted value of the secret
+func (session *Session) GetSecret(parameters *types.SecretParameters) (string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	name := getSecretParameterName(parameters.EnvironmentName, parameters.AppName, parameters.Key)
+	value, err := session.NewSsmSession().GetParameterValue(name)
+	if err != nil {
+		if isParameterNotFound(err) {
+			err = types.NewUserError(fmt.Sprintf("get secret %q", name), fmt.Errorf("%s has no secret %s", parameters.AppName, parameters.Key))
+		} else {
+			err = types.NewAwsError(fmt.Sprintf("get secret %q", name), err)
+		}
+		log.Fail(err)
+		return "", err
+	}
+	log.Succeed()
+	return *value, nil
+}
+
+// ListSecrets returns the keys of the secrets of the app in order
+func (session *Session) ListSecrets(parameters *types.SecretParameters) ([]string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	secrets, err := session.getSecretParameterNames(parameters.EnvironmentName, parameters.AppName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("list secrets of %s", parameters.AppName), err)
+		log.Fail(err)
+		return nil, err
+	}
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	log.Succeed()
+	return keys, nil
+}
+
+// RemoveSecret deletes the secret, the app keeps it till it is deployed again
+func (session *Session) RemoveSecret(parameters *types.SecretParameters) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	name := getSecretParameterName(parameters.EnvironmentName, parameters.AppName, parameters.Key)
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete secret %q", name)) {
+		return nil
+	}
+	if err := session.NewSsmSession().DeleteParameter(name); err != nil {
+		if isParameterNotFound(err) {
+			err = types.NewUserError(fmt.Sprintf("delete secret %q", name), fmt.Errorf("%s has no secret %s", parameters.AppName, parameters.Key))
+		} else {
+			err = types.NewAwsError(fmt.Sprintf("delete secret %q", name), err)
+		}
+		log.Fail(err)
+		return err
+	}
+	log.Succeedf("Deleted %s, deploy %s to stop passing it to the app", name, parameters.AppName)
+	return nil
+}
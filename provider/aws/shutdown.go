@@ -0,0 +1,153 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// getScheduleExpression returns the eventbridge cron expression that fires once at the deadline, rounded up to the minute
+func getScheduleExpression(deadline time.Time) string {
+	deadline = deadline.UTC()
+	if rounded := deadline.Truncate(time.Minute); rounded.Before(deadline) {
+		deadline = rounded.Add(time.Minute)
+	}
+	return fmt.Sprintf("cron(%d %d %d %d ? %d)",
+		deadline.Minute(), deadline.Hour(), deadline.Day(), int(deadline.Month()), deadline.Year())
+}
+
+// scheduleShutdown creates or updates the stack whose rule sets the spot fleet capacity to 0 and deletes the public ip stack at the deadline
+func (session *Session) scheduleShutdown(deadline time.Time) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	config := session.GetComputeConfig()
+
+	stack := NewStack(config.ShutdownStackName, session)
+	err := stack.Update("shutdown.yml", []cloudformation.Parameter{
+		{
+			ParameterKey:   aws.String("SpotFleetStackName"),
+			ParameterValue: aws.String(config.EcsSpotFleetStackName),
+		},
+		{
+			ParameterKey:   aws.String("PublicIpStackName"),
+			ParameterValue: aws.String(config.PublicIPStackName),
+		},
+		{
+			ParameterKey:   aws.String("ScheduleExpression"),
+			ParameterValue: aws.String(getScheduleExpression(deadline)),
+		},
+		{
+			ParameterKey:   aws.String("Deadline"),
+			ParameterValue: aws.String(deadline.UTC().Format(time.RFC3339)),
+		},
+	})
+	if err != nil {
+		err = types.NewAwsError("schedule the shutdown", err)
+		log.Fail(err)
+		return err
+	}
+	log.Result("shutdownAt", deadline)
+	log.Succeedf("Shutdown scheduled at %s", deadline.Local().Format("Mon 2 Jan 15:04 MST"))
+	return nil
+}
+
+// getShutdownDeadline returns nil when no shutdown is scheduled
+func (session *Session) getShutdownDeadline() (*time.Time, error) {
+	stackName := session.GetComputeConfig().ShutdownStackName
+	description, err := NewStack(stackName, session).Describe()
+	if err != nil {
+		return nil, err
+	}
+	if description == nil || !isStackAvailable(string(description.StackStatus)) {
+		return nil, nil
+	}
+	for _, output := range description.Outputs {
+		if aws.StringValue(output.OutputKey) != "Deadline" {
+			continue
+		}
+		deadline, err := time.Parse(time.RFC3339, aws.StringValue(output.OutputValue))
+		if err != nil {
+			return nil, fmt.Errorf("stack %s has an invalid deadline, %w", stackName, err)
+		}
+		return &deadline, nil
+	}
+	return nil, fmt.Errorf("stack %s does not have the expected output", stackName)
+}
+
+// deleteShutdownSchedule removes the schedule of an environment that no longer runs
+func (session *Session) deleteShutdownSchedule() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	stackName := session.GetComputeConfig().ShutdownStackName
+	stack := NewStack(stackName, session)
+	description, err := stack.Describe()
+	if err != nil {
+		err = types.NewAwsError("get shutdown schedule details", err)
+		log.Fail(err)
+		return err
+	}
+	if description == nil {
+		log.Skipf("No shutdown scheduled")
+		return nil
+	}
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
+	if _, err := stack.Delete(); err != nil {
+		err = types.NewAwsError("delete shutdown schedule", err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+// GetShutdownSchedule returns when the environment shuts itself down, a deadline that passed is the shutdown
+// that already happened, the schedule stack stays till stop, teardown or the next start with a ttl
+func (session *Session) GetShutdownSchedule() (*types.ShutdownSchedule, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	deadline, err := session.getShutdownDeadline()
+	if err != nil {
+		err = types.NewAwsError("get shutdown schedule", err)
+		log.Fail(err)
+		return nil, err
+	}
+	log.Succeed()
+	return &types.ShutdownSchedule{
+		Deadline: deadline,
+		Passed:   deadline != nil && deadline.Before(time.Now()),
+	}, nil
+}
+
+// ExtendShutdown postpones the scheduled shutdown, a deadline that passed already stopped the environment
+func (session *Session) ExtendShutdown(parameters *types.ExtendShutdownParameters) (*types.ShutdownSchedule, error) {
+	log := logger.New()
+	defer log.LogDone()
+	deadline, err := session.getShutdownDeadline()
+	if err != nil {
+		return nil, types.NewAwsError("get shutdown schedule", err)
+	}
+	if deadline == nil {
+		return nil, types.NewUserError("extend the shutdown",
+			fmt.Errorf("no shutdown is scheduled, run start with --ttl or --stop-at to schedule one"))
+	}
+	if deadline.Before(time.Now()) {
+		return nil, types.NewUserError("extend the shutdown",
+			fmt.Errorf("the shutdown at %s already happened, run start with --ttl or --stop-at to start the environment and schedule a new one",
+				deadline.Local().Format("Mon 2 Jan 15:04 MST")))
+	}
+	newDeadline := parameters.StopAt
+	if newDeadline == nil {
+		extended := deadline.Add(parameters.By)
+		newDeadline = &extended
+	}
+	if err := session.scheduleShutdown(*newDeadline); err != nil {
+		return nil, err
+	}
+	return &types.ShutdownSchedule{Deadline: newDeadline}, nil
+}
@@ -0,0 +1,206 @@
+package aws
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/kahgeh/devenv/fixed"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils"
+)
+
+const sshUser = "ec2-user"
+
+type sshTarget struct {
+	publicIP       string
+	instanceID     string
+	keyFilePath    string
+	knownHostsPath string
+	// instancePath remembers the instance the known hosts belong to
+	instancePath string
+}
+
+func getSshStateFolderPath() string {
+	return fmt.Sprintf("%s/ssh", fixed.GetConfigFolderPath())
+}
+
+func getSshHostAlias(envName string) string {
+	return fmt.Sprintf("devenv-%s", envName)
+}
+
+// resolveSshTarget finds the instance the public ip is attached to and the key to reach it with
+func (session *Session) resolveSshTarget(envName string) (*sshTarget, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	config := session.GetComputeConfig()
+
+	log.Info("getting public ip details...")
+	publicIPStatus, err := session.getStackStatus("public-ip", config.PublicIPStackName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("get status of stack %q", config.PublicIPStackName), err)
+		log.Fail(err)
+		return nil, err
+	}
+	if !isStackAvailable(publicIPStatus.Status) {
+		err = types.NewUserError("find the instance",
+			fmt.Errorf("public ip stack %q is %s, start the environment first", config.PublicIPStackName, publicIPStatus.Status))
+		log.Fail(err)
+		return nil, err
+	}
+	publicIP, err := session.getStackOutputValueByKey("PublicIp", config.PublicIPStackName)
+	if err != nil {
+		err = types.NewAwsError("get public ip details", err)
+		log.Fail(err)
+		return nil, err
+	}
+	address, err := session.getPublicIPStatus(publicIP, "")
+	if err != nil {
+		err = types.NewAwsError("get public ip details", err)
+		log.Fail(err)
+		return nil, err
+	}
+	if address.InstanceID == "" {
+		err = types.NewUserError("find the instance",
+			fmt.Errorf("public ip %s is not attached to an instance, start the environment first", *publicIP))
+		log.Fail(err)
+		return nil, err
+	}
+
+	keyFilePath := fmt.Sprintf("%s/%s.pem", utils.GetSshFolderPath(), config.KeyPairName)
+	if _, err := os.Stat(keyFilePath); err != nil {
+		err = types.NewUserError(fmt.Sprintf("find private key %s", keyFilePath), err)
+		log.Fail(err)
+		return nil, err
+	}
+	log.Succeed()
+	return &sshTarget{
+		publicIP:       address.PublicIP,
+		instanceID:     address.InstanceID,
+		keyFilePath:    keyFilePath,
+		knownHostsPath: fmt.Sprintf("%s/%s_known_hosts", getSshStateFolderPath(), envName),
+		instancePath:   fmt.Sprintf("%s/%s_instance", getSshStateFolderPath(), envName),
+	}, nil
+}
+
+// refreshKnownHosts forgets the host key of the previous instance once a different instance is attached
+func (session *Session) refreshKnownHosts(target *sshTarget) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	if err := utils.CreateFolderIfNotExist(getSshStateFolderPath()); err != nil {
+		err = types.NewError(fmt.Sprintf("ensure %q exist", getSshStateFolderPath()), err)
+		log.Fail(err)
+		return err
+	}
+
+	previousInstanceID, _ := ioutil.ReadFile(target.instancePath)
+	if string(previousInstanceID) == target.instanceID {
+		log.Succeed()
+		return nil
+	}
+
+	log.Infof("instance changed to %s, resetting known hosts...", target.instanceID)
+	if err := os.Remove(target.knownHostsPath); err != nil && !os.IsNotExist(err) {
+		err = types.NewError(fmt.Sprintf("reset %s", target.knownHostsPath), err)
+		log.Fail(err)
+		return err
+	}
+	if err := ioutil.WriteFile(target.instancePath, []byte(target.instanceID), 0600); err != nil {
+		err = types.NewError(fmt.Sprintf("save %s", target.instancePath), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+func getSshConfigBlock(envName string, target *sshTarget) string {
+	alias := getSshHostAlias(envName)
+	return fmt.Sprintf(`# begin %[1]s
+Host %[1]s
+  HostName %[2]s
+  User %[3]s
+  IdentityFile %[4]s
+  UserKnownHostsFile %[5]s
+  StrictHostKeyChecking accept-new
+# end %[1]s
+`, alias, target.publicIP, sshUser, target.keyFilePath, target.knownHostsPath)
+}
+
+// writeSshConfig adds the host block of the environment to ~/.ssh/config, replacing the one written before
+func (session *Session) writeSshConfig(envName string, target *sshTarget) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	sshFolderPath := utils.GetSshFolderPath()
+	if err := utils.CreateFolderIfNotExist(sshFolderPath); err != nil {
+		err = types.NewError(fmt.Sprintf("ensure %q exist", sshFolderPath), err)
+		log.Fail(err)
+		return err
+	}
+	configFilePath := fmt.Sprintf("%s/config", sshFolderPath)
+	content, err := ioutil.ReadFile(configFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		err = types.NewError(fmt.Sprintf("read %s", configFilePath), err)
+		log.Fail(err)
+		return err
+	}
+
+	alias := regexp.QuoteMeta(getSshHostAlias(envName))
+	existingBlock := regexp.MustCompile(fmt.Sprintf(`(?ms)^# begin %[1]s\n.*?^# end %[1]s\n?`, alias))
+	block := getSshConfigBlock(envName, target)
+	config := string(content)
+	if existingBlock.MatchString(config) {
+		config = existingBlock.ReplaceAllLiteralString(config, block)
+	} else {
+		if len(config) > 0 && !strings.HasSuffix(config, "\n") {
+			config += "\n"
+		}
+		config += block
+	}
+
+	if err := ioutil.WriteFile(configFilePath, []byte(config), 0600); err != nil {
+		err = types.NewError(fmt.Sprintf("write %s", configFilePath), err)
+		log.Fail(err)
+		return err
+	}
+	log.Result("sshHost", getSshHostAlias(envName))
+	log.Succeedf("Added host %s to %s", getSshHostAlias(envName), configFilePath)
+	return nil
+}
+
+// Ssh opens a shell on the instance the public ip is attached to, or runs a command there
+func (session *Session) Ssh(parameters *types.SshParameters) error {
+	log := logger.New()
+	defer log.LogDone()
+	target, err := session.resolveSshTarget(parameters.EnvironmentName)
+	if err != nil {
+		return err
+	}
+	if err := session.refreshKnownHosts(target); err != nil {
+		return err
+	}
+	if parameters.WriteConfig {
+		return session.writeSshConfig(parameters.EnvironmentName, target)
+	}
+
+	args := []string{
+		"-i", target.keyFilePath,
+		"-o", fmt.Sprintf("UserKnownHostsFile=%s", target.knownHostsPath),
+		"-o", "StrictHostKeyChecking=accept-new",
+		fmt.Sprintf("%s@%s", sshUser, target.publicIP),
+	}
+	command := exec.Command("ssh", append(args, parameters.Command...)...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		err = types.NewError(fmt.Sprintf("ssh to %s", target.publicIP), err)
+		log.Fail(err)
+		return err
+	}
+	return nil
+}
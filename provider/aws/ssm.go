@@ -1,14 +1,16 @@
 package aws
 
 import (
+	"context"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/external"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
-	"github.com/kahgeh/devenv/utils/ctx"
 )
 
 type SsmSession struct {
 	api *ssm.Client
+	ctx context.Context
 }
 
 func NewSession() (*Session, error) {
@@ -24,6 +26,7 @@ func (session *Session) NewSsmSession() *SsmSession {
 	api := ssm.New(session.config)
 	return &SsmSession{
 		api: api,
+		ctx: session.getContext(),
 	}
 }
 
@@ -34,7 +37,7 @@ func (session *SsmSession) GetParameterValue(name string) (value *string, err er
 		Name:           aws.String(name),
 		WithDecryption: aws.Bool(true),
 	})
-	response, err := request.Send(ctx.GetContext())
+	response, err := request.Send(session.ctx)
 	if err != nil {
 		value = nil
 		return
@@ -52,10 +55,103 @@ func (session *SsmSession) SaveParameter(name string, value string) (version *in
 		Type:      ssm.ParameterTypeString,
 		Overwrite: aws.Bool(true),
 	})
-	response, err := request.Send(ctx.GetContext())
+	response, err := request.Send(session.ctx)
+	if err != nil {
+		return
+	}
+	version = response.Version
+	return
+}
+
+// SaveTieredParameter saves a value that can be over the 4 KB of a standard parameter, only such values are
+// stored as advanced parameters
+func (session *SsmSession) SaveTieredParameter(name string, value string) (version *int64, err error) {
+	request := session.api.PutParameterRequest(&ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     &value,
+		Type:      ssm.ParameterTypeString,
+		Tier:      ssm.ParameterTierIntelligentTiering,
+		Overwrite: aws.Bool(true),
+	})
+	response, err := request.Send(session.ctx)
+	if err != nil {
+		return
+	}
+	version = response.Version
+	return
+}
+
+// SaveSecureParameter encrypts the value with the kms key, e.g. the aws/ssm key of the environment
+func (session *SsmSession) SaveSecureParameter(name string, value string, keyID string) (version *int64, err error) {
+	request := session.api.PutParameterRequest(&ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     &value,
+		Type:      ssm.ParameterTypeSecureString,
+		KeyId:     aws.String(keyID),
+		Overwrite: aws.Bool(true),
+	})
+	response, err := request.Send(session.ctx)
 	if err != nil {
 		return
 	}
 	version = response.Version
 	return
 }
+
+// DeleteParameter deletes the parameter, aws reports ParameterNotFound when it does not exist
+func (session *SsmSession) DeleteParameter(name string) error {
+	request := session.api.DeleteParameterRequest(&ssm.DeleteParameterInput{
+		Name: aws.String(name),
+	})
+	_, err := request.Send(session.ctx)
+	return err
+}
+
+// GetParameterNamesByPath lists the names of every parameter under the path, including nested ones
+func (session *SsmSession) GetParameterNamesByPath(path string) (names []string, err error) {
+	paginator := ssm.NewGetParametersByPathPaginator(session.api.GetParametersByPathRequest(&ssm.GetParametersByPathInput{
+		Path:      aws.String(path),
+		Recursive: aws.Bool(true),
+	}))
+	for paginator.Next(session.ctx) {
+		for _, parameter := range paginator.CurrentPage().Parameters {
+			names = append(names, *parameter.Name)
+		}
+	}
+	err = paginator.Err()
+	return
+}
+
+// GetParameterValuesByPath returns the value of every parameter under the path by name, including nested ones
+func (session *SsmSession) GetParameterValuesByPath(path string) (values map[string]string, err error) {
+	paginator := ssm.NewGetParametersByPathPaginator(session.api.GetParametersByPathRequest(&ssm.GetParametersByPathInput{
+		Path:      aws.String(path),
+		Recursive: aws.Bool(true),
+	}))
+	values = map[string]string{}
+	for paginator.Next(session.ctx) {
+		for _, parameter := range paginator.CurrentPage().Parameters {
+			values[*parameter.Name] = aws.StringValue(parameter.Value)
+		}
+	}
+	err = paginator.Err()
+	return
+}
+
+// DeleteParameters deletes the parameters, in batches of the maximum aws accepts per request
+func (session *SsmSession) DeleteParameters(names []string) error {
+	const batchSize = 10
+	for start := 0; start < len(names); start += batchSize {
+		end := start + batchSize
+		if end > len(names) {
+			end = len(names)
+		}
+		request := session.api.DeleteParametersRequest(&ssm.DeleteParametersInput{
+			Names: names[start:end],
+		})
+		if _, err := request.Send(session.ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
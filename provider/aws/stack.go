@@ -1,6 +1,7 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 	"github.com/kahgeh/devenv/provider/aws/errors"
 	"strings"
@@ -10,30 +11,48 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/kahgeh/devenv/logger"
-	"github.com/kahgeh/devenv/utils/ctx"
 )
 
 type StackUpdateCompletion string
 
 type Stack struct {
-	api     *cloudformation.Client
-	name    string
-	details *cloudformation.Stack
+	api      *cloudformation.Client
+	name     string
+	details  *cloudformation.Stack
+	planOnly bool
+	// autoRecover allows stacks stuck in an unrecoverable state to be deleted and created again
+	autoRecover bool
+	// ctx is the context of the session the stack was created with
+	ctx context.Context
+	// recordOutput and recordedOutput save and read the change set executed by the step of the session in the journal
+	recordOutput   func(key string, value string)
+	recordedOutput func(key string) (string, bool)
+	// templateData renders the template of the stack, see getCfnTemplateContent
+	templateData interface{}
 }
 
 func NewStack(name string, awsSession *Session) *Stack {
 	return &Stack{
-		api:  cloudformation.New(awsSession.config),
-		name: name,
+		api:            cloudformation.New(awsSession.config),
+		name:           name,
+		planOnly:       awsSession.plan,
+		autoRecover:    awsSession.autoRecover,
+		ctx:            awsSession.getContext(),
+		recordOutput:   awsSession.recordOutput,
+		recordedOutput: awsSession.recordedOutput,
 	}
 }
 
+// WithTemplateData renders the template with the data before creating or updating the stack
+func (stack *Stack) WithTemplateData(data interface{}) *Stack {
+	stack.templateData = data
+	return stack
+}
+
 // CreateChangeSet create a changeset
-func (stack *Stack) CreateChangeSet(name string, changesetType cloudformation.ChangeSetType, templateBody string, parameters []cloudformation.Parameter) *ChangeSet {
+func (stack *Stack) CreateChangeSet(name string, changesetType cloudformation.ChangeSetType, templateBody string, parameters []cloudformation.Parameter) (*ChangeSet, error) {
 	log := logger.New()
-	if stack == nil {
-		return nil
-	}
+	defer log.LogDone()
 	stackName := stack.name
 	token := fmt.Sprintf("%s-%v", stackName, time.Now().UnixNano())
 
@@ -47,16 +66,13 @@ func (stack *Stack) CreateChangeSet(name string, changesetType cloudformation.Ch
 		Parameters:    parameters,
 	})
 
-	response, err := request.Send(ctx.GetContext())
+	response, err := request.Send(stack.ctx)
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("error creating change set request")
-		return nil
+		return nil, fmt.Errorf("cannot create change set %s, %w", name, err)
 	}
 	description, err := stack.Describe()
 	if err != nil {
-		log.Fail("unable to get description of stack '%s'", stackName)
-		return nil
+		return nil, fmt.Errorf("unable to get description of stack '%s', %w", stackName, err)
 	}
 	log.Infof("created changeset, name=%q", name)
 
@@ -64,19 +80,22 @@ func (stack *Stack) CreateChangeSet(name string, changesetType cloudformation.Ch
 		id:   *response.Id,
 		name: name,
 		stack: Stack{
-			api:     stack.api,
-			name:    stackName,
-			details: description,
+			api:            stack.api,
+			name:           stackName,
+			details:        description,
+			planOnly:       stack.planOnly,
+			autoRecover:    stack.autoRecover,
+			ctx:            stack.ctx,
+			recordOutput:   stack.recordOutput,
+			recordedOutput: stack.recordedOutput,
 		},
-	}
+	}, nil
 }
 
 // Delete remove stack, waits for completion
-func (stack *Stack) Delete() *string {
+func (stack *Stack) Delete() (*string, error) {
 	log := logger.New()
-	if stack == nil {
-		return nil
-	}
+	defer log.LogDone()
 	stackName := stack.name
 	token := fmt.Sprintf("%s-%v", stackName, time.Now().UnixNano())
 	api := stack.api
@@ -84,43 +103,57 @@ func (stack *Stack) Delete() *string {
 		StackName:          aws.String(stackName),
 		ClientRequestToken: aws.String(token),
 	})
-	_, err := request.Send(ctx.GetContext())
+	operation, err := trackStackAction(stack, "being deleted")
 	if err != nil {
-		log.Info(err)
-		if awsErr, ok := err.(awserr.Error); ok {
-			log.Info(awsErr.Code())
-			log.Debug(awsErr.Error())
-			log.Debug(awsErr.Message())
-		}
-
-		return &token
+		return nil, err
 	}
-	err = api.WaitUntilStackDeleteComplete(ctx.GetContext(),
+	defer operation.done()
+	if _, err := operation.execute(func() (*string, error) {
+		_, err := request.Send(stack.ctx)
+		return &token, err
+	}); err != nil {
+		return nil, err
+	}
+	streamer := stack.StreamEvents(&token, log)
+	defer streamer.Stop()
+	err = api.WaitUntilStackDeleteComplete(stack.ctx,
 		&cloudformation.DescribeStacksInput{
 			StackName: aws.String(stackName),
 		})
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("fail to wait for stack deletion to complete")
-		return nil
+		return nil, stack.diagnose(token, err)
+	}
+	return &token, nil
+}
+
+// GetParameterValue returns the value the stack was created or last updated with, nil when the stack does not exist
+// or has no such parameter
+func (stack *Stack) GetParameterValue(name string) (*string, error) {
+	description, err := stack.Describe()
+	if err != nil || description == nil {
+		return nil, err
+	}
+	for _, parameter := range description.Parameters {
+		if aws.StringValue(parameter.ParameterKey) == name {
+			return parameter.ParameterValue, nil
+		}
 	}
-	return &token
+	return nil, nil
 }
 
 func (stack *Stack) Describe() (description *cloudformation.Stack, err error) {
 	request := stack.api.DescribeStacksRequest(&cloudformation.DescribeStacksInput{
 		StackName: aws.String(stack.name),
 	})
-	response, err := request.Send(ctx.GetContext())
+	response, err := request.Send(stack.ctx)
 	if err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == "ValidationError" &&
 				strings.Contains(awsErr.Message(), "does not exist") {
 				return nil, nil
 			}
-		} else {
-			return nil, err
 		}
+		return nil, err
 	}
 	if response == nil {
 		return nil, &errors.NoResponse{Message: "empty response from describe stack"}
@@ -129,13 +162,57 @@ func (stack *Stack) Describe() (description *cloudformation.Stack, err error) {
 	return &response.Stacks[0], nil
 }
 
-// GetEvents retrieves the events for a specific operation
+// isOperationStart is true for the event of the stack itself that opens an operation
+func isOperationStart(event cloudformation.StackEvent) bool {
+	if aws.StringValue(event.PhysicalResourceId) != aws.StringValue(event.StackId) {
+		return false
+	}
+	switch event.ResourceStatus {
+	case cloudformation.ResourceStatusCreateInProgress,
+		cloudformation.ResourceStatusUpdateInProgress,
+		cloudformation.ResourceStatusDeleteInProgress,
+		cloudformation.ResourceStatusImportInProgress:
+		return true
+	}
+	return false
+}
+
+// GetEvents retrieves the events for a specific operation, reading pages till the first event of the operation
 func (stack *Stack) GetEvents(opToken string) (events []cloudformation.StackEvent, err error) {
-	request := stack.api.DescribeStackEventsRequest(&cloudformation.DescribeStackEventsInput{
-		StackName: aws.String(stack.name),
+	isOperationEvent := func(event cloudformation.StackEvent) bool {
+		return event.ClientRequestToken != nil &&
+			*event.ClientRequestToken == opToken
+	}
+	found := false
+	return stack.getEvents(isOperationEvent, func(event cloudformation.StackEvent) bool {
+		if !isOperationEvent(event) {
+			// the events of an earlier operation follow the first event of this one
+			return found
+		}
+		found = true
+		return isOperationStart(event)
 	})
-	response, err := request.Send(ctx.GetContext())
-	if err != nil {
+}
+
+// getEvents retrieves the events that satisfy the predicate, most recent first, reading pages till isLast is true
+// for an event or there are no more events
+func (stack *Stack) getEvents(predicate func(cloudformation.StackEvent) bool,
+	isLast func(cloudformation.StackEvent) bool) (events []cloudformation.StackEvent, err error) {
+	paginator := cloudformation.NewDescribeStackEventsPaginator(
+		stack.api.DescribeStackEventsRequest(&cloudformation.DescribeStackEventsInput{
+			StackName: aws.String(stack.name),
+		}))
+	for paginator.Next(stack.ctx) {
+		for _, event := range paginator.CurrentPage().StackEvents {
+			if predicate(event) {
+				events = append(events, event)
+			}
+			if isLast(event) {
+				return events, nil
+			}
+		}
+	}
+	if err := paginator.Err(); err != nil {
 		if awsErr, ok := err.(awserr.Error); ok {
 			if awsErr.Code() == "ValidationError" &&
 				strings.Contains(awsErr.Message(), "does not exist") {
@@ -144,17 +221,131 @@ func (stack *Stack) GetEvents(opToken string) (events []cloudformation.StackEven
 		}
 		return nil, err
 	}
-	for _, event := range response.StackEvents {
-		if event.ClientRequestToken != nil &&
-			*event.ClientRequestToken == opToken {
-			events = append(events, event)
+
+	return events, nil
+}
+
+// previewChangeSet prints the changes of a change set and then removes it, leaving the stack untouched
+func (stack *Stack) previewChangeSet(name string, changesetType cloudformation.ChangeSetType, templateBody string, parameters []cloudformation.Parameter) error {
+	log := logger.New()
+	defer log.LogDone()
+	changeSet, err := stack.CreateChangeSet(name, changesetType, templateBody, parameters)
+	if err != nil {
+		return err
+	}
+	operation, err := trackChangeSet(stack, changeSet, changesetType)
+	if err != nil {
+		return err
+	}
+	defer operation.done()
+	changeSet, err = changeSet.WaitTillExecutable()
+	if err != nil {
+		return err
+	}
+	log.Print(changeSet.GetPlan())
+	if err := changeSet.Delete(); err != nil {
+		log.Debug(err.Error())
+		log.Infof("fail to delete change set %s", changeSet.name)
+	}
+	if changesetType == cloudformation.ChangeSetTypeCreate {
+		// a create change set leaves an empty stack in REVIEW_IN_PROGRESS behind
+		if _, err := stack.Delete(); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	return events, nil
+// executeChangeSet creates the change set, runs it and reports its events till the stack completes the operation
+func (stack *Stack) executeChangeSet(name string, changesetType cloudformation.ChangeSetType, templateBody string, parameters []cloudformation.Parameter) error {
+	log := logger.New()
+	defer log.LogDone()
+	changeSet, err := stack.CreateChangeSet(name, changesetType, templateBody, parameters)
+	if err != nil {
+		return err
+	}
+	stack.recordOutput(stack.changeSetKey(), changeSet.id)
+	operation, err := trackChangeSet(stack, changeSet, changesetType)
+	if err != nil {
+		return err
+	}
+	defer operation.done()
+	changeSet, err = changeSet.WaitTillExecutable()
+	if err != nil {
+		return err
+	}
+	if isWithoutChanges(changeSet.details) {
+		log.Debugf("Stack %v is up to date", stack.name)
+		if err := changeSet.Delete(); err != nil {
+			log.Debug(err.Error())
+		}
+		return nil
+	}
+	opToken, err := operation.execute(changeSet.SendExecuteRequest)
+	if err != nil {
+		return fmt.Errorf("fail to execute change set %s, %w", name, err)
+	}
+	streamer := stack.StreamEvents(opToken, log)
+	defer streamer.Stop()
+	input := &cloudformation.DescribeStacksInput{
+		StackName: &stack.name,
+	}
+	if changesetType == cloudformation.ChangeSetTypeCreate {
+		err = stack.api.WaitUntilStackCreateComplete(stack.ctx, input)
+	} else {
+		err = stack.api.WaitUntilStackUpdateComplete(stack.ctx, input)
+	}
+	if err != nil {
+		return stack.diagnose(*opToken, err)
+	}
+	return nil
+}
+
+// changeSetKey names the output recording the change set of the stack, a step can create or update several stacks
+func (stack *Stack) changeSetKey() string {
+	return fmt.Sprintf("changeSetId/%s", stack.name)
 }
 
-func getNameFromStackFileName(actionName string,stackFileName string) string{
+// resumeChangeSet settles the change set recorded by an interrupted run of the step before another one is created,
+// one still executing is left for recover to wait for and one that was never executed is deleted
+func (stack *Stack) resumeChangeSet() error {
+	id, recorded := stack.recordedOutput(stack.changeSetKey())
+	if !recorded || id == "" || stack.planOnly {
+		return nil
+	}
+	log := logger.New()
+	defer log.LogDone()
+	changeSet := &ChangeSet{id: id, name: id, stack: *stack}
+	details, err := changeSet.Describe()
+	if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == cloudformation.ErrCodeChangeSetNotFoundException {
+		log.Debugf("change set %s recorded by the previous run no longer exists", id)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot describe change set %s recorded by the previous run, %w", id, err)
+	}
+	switch {
+	case details.ExecutionStatus == cloudformation.ExecutionStatusExecuteInProgress:
+		log.Infof("change set %s of the previous run is still executing", aws.StringValue(details.ChangeSetName))
+		return nil
+	case details.ExecutionStatus == cloudformation.ExecutionStatusUnavailable &&
+		!containStatus(details.Status, ChangeSetCompletionStatuses):
+		if _, err := changeSet.WaitTillExecutable(); err != nil {
+			log.Debugf("change set %s of the previous run, %v", id, err)
+		}
+	case details.ExecutionStatus != cloudformation.ExecutionStatusAvailable &&
+		details.Status != cloudformation.ChangeSetStatusFailed:
+		return nil
+	}
+	log.Infof("deleting change set %s left by the previous run...", aws.StringValue(details.ChangeSetName))
+	if err := changeSet.Delete(); err != nil {
+		return fmt.Errorf("cannot delete change set %s left by the previous run, %w", aws.StringValue(details.ChangeSetName), err)
+	}
+	stack.recordOutput(stack.changeSetKey(), "")
+	return nil
+}
+
+func getNameFromStackFileName(actionName string, stackFileName string) string {
 	return fmt.Sprintf("%s-%s", actionName, strings.Replace(
 		strings.TrimRight(stackFileName, ".yml"),
 		"/", "-", -1))
@@ -167,44 +358,36 @@ func (stack *Stack) Create(stackFileName string, parameters []cloudformation.Par
 	defer log.LogDone()
 	stackName := stack.name
 	changeSetName := getNameFromStackFileName("create", stackFileName)
-	templateBody, err := getCfnTemplateContent(stackFileName)
+	templateBody, err := getCfnTemplateContent(stackFileName, stack.templateData)
 	if err != nil {
-		log.Failf("cannot retrieve %s", stackFileName)
+		return fmt.Errorf("cannot retrieve %s, %w", stackFileName, err)
 	}
 	log.Debugf("template body \n%s", templateBody)
 
+	if err := stack.resumeChangeSet(); err != nil {
+		return err
+	}
+
 	cfnStack, err := stack.Describe()
 	if err != nil {
 		log.Debugf("unexpected error occured while checking if stack '%s' exist ", stackName)
 		return err
 	}
 
-	if cfnStack != nil {
-		log.Debugf("Stack %v already exists", stackName)
-		return nil
-	}
-
-	opToken := stack.
-		CreateChangeSet(changeSetName, cloudformation.ChangeSetTypeCreate, templateBody, parameters).
-		WaitTillExecutable().
-		SendExecuteRequest()
-	api := stack.api
-	err = api.WaitUntilStackCreateComplete(ctx.GetContext(), &cloudformation.DescribeStacksInput{
-		StackName: &stack.name,
-	})
+	cfnStack, err = stack.recover(cfnStack)
 	if err != nil {
 		return err
 	}
 
-	events, err := stack.GetEvents(*opToken)
-	var eventsSummary strings.Builder
-	for _, event := range events {
-		eventsSummary.WriteString(fmt.Sprintf("%s [%s] %s\n",
-			event.Timestamp.Format(time.Kitchen),
-			event.ResourceStatus, *event.LogicalResourceId))
+	if cfnStack != nil && cfnStack.StackStatus != cloudformation.StackStatusReviewInProgress {
+		log.Debugf("Stack %v already exists", stackName)
+		return nil
+	}
+
+	if stack.planOnly {
+		return stack.previewChangeSet(changeSetName, cloudformation.ChangeSetTypeCreate, templateBody, parameters)
 	}
-	log.Debugf("events :\n%s", eventsSummary.String())
-	return nil
+	return stack.executeChangeSet(changeSetName, cloudformation.ChangeSetTypeCreate, templateBody, parameters)
 }
 
 // Update creates stack, reports success if successfully create as well as if it already exist
@@ -215,43 +398,36 @@ func (stack *Stack) Update(stackFileName string, parameters []cloudformation.Par
 	stackName := stack.name
 	changeSetName := getNameFromStackFileName("update", stackFileName)
 
-	templateBody, err := getCfnTemplateContent(stackFileName)
+	templateBody, err := getCfnTemplateContent(stackFileName, stack.templateData)
 	if err != nil {
-		log.Failf("cannot retrieve %s", stackFileName)
+		return fmt.Errorf("cannot retrieve %s, %w", stackFileName, err)
 	}
 	log.Debugf("template body \n%s", templateBody)
 
-	_, err = stack.Describe()
+	if err := stack.resumeChangeSet(); err != nil {
+		return err
+	}
+
+	cfnStack, err := stack.Describe()
 	if err != nil {
 		log.Debugf("unexpected error occurred while checking if stack '%s' exist ", stackName)
 		return err
 	}
 
-	opToken := stack.
-		CreateChangeSet(
-			changeSetName,
-			cloudformation.ChangeSetTypeUpdate,
-			templateBody,
-			parameters).
-		WaitTillExecutable().
-		SendExecuteRequest()
-	api := stack.api
-	err = api.WaitUntilStackUpdateComplete(ctx.GetContext(),
-		&cloudformation.DescribeStacksInput{
-			StackName: &stack.name,
-		})
-
-	if err == nil {
+	cfnStack, err = stack.recover(cfnStack)
+	if err != nil {
 		return err
 	}
+	if cfnStack == nil || cfnStack.StackStatus == cloudformation.StackStatusReviewInProgress {
+		log.Debugf("Stack %v no longer exists, creating it", stackName)
+		return stack.Create(stackFileName, parameters)
+	}
+	if stack.planOnly && isStackUnrecoverable(cfnStack.StackStatus) {
+		return nil
+	}
 
-	events, err := stack.GetEvents(*opToken)
-	var eventsSummary strings.Builder
-	for _, event := range events {
-		eventsSummary.WriteString(fmt.Sprintf("%s [%s] %s\n",
-			event.Timestamp.Format(time.Kitchen),
-			event.ResourceStatus, *event.LogicalResourceId))
+	if stack.planOnly {
+		return stack.previewChangeSet(changeSetName, cloudformation.ChangeSetTypeUpdate, templateBody, parameters)
 	}
-	log.Debugf("events :\n%s", eventsSummary.String())
-	return nil
+	return stack.executeChangeSet(changeSetName, cloudformation.ChangeSetTypeUpdate, templateBody, parameters)
 }
@@ -1,17 +1,24 @@
 package aws
 
 import (
+	"context"
 	"fmt"
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
 	"github.com/kahgeh/devenv/provider/types"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/kahgeh/devenv/logger"
-	"github.com/kahgeh/devenv/utils/ctx"
+	"github.com/kahgeh/devenv/provider/journal"
 )
 
+// frontProxyPlacementTimeout bounds the wait for ecs to place the front proxy on one of the new instances
+const frontProxyPlacementTimeout = 5 * time.Minute
+
 type waitResult struct {
 	output *string
 	err    error
@@ -28,14 +35,13 @@ func filterOutputs(outputs []cloudformation.Output, predicate func(cloudformatio
 	return b
 }
 
-func (session *Session) createPublicIP(hostedZoneName string, domainName string) *string {
+func (session *Session) createPublicIP(hostedZoneName string, domainName string) (*string, error) {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	cfg := session.GetComputeConfig()
 	stackName := cfg.PublicIPStackName
 	log.Debugf("hostedZoneName=%q", hostedZoneName)
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
+	stack := NewStack(stackName, session)
 	err := stack.Create("publicIp.yml", []cloudformation.Parameter{
 		{
 			ParameterKey:   aws.String("HostedZoneName"),
@@ -47,36 +53,54 @@ func (session *Session) createPublicIP(hostedZoneName string, domainName string)
 		},
 	})
 	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("fail to create public Ip")
+		err = types.NewAwsError("create public ip", err)
+		log.Fail(err)
+		return nil, err
+	}
+	if session.plan {
+		log.Succeed()
+		return nil, nil
+	}
+	publicIP, err := session.getStackOutputValueByKey("PublicIp", stackName)
+	if err != nil {
+		err = types.NewAwsError("get public ip", err)
+		log.Fail(err)
+		return nil, err
 	}
-	publicIP := session.getStackOutputValue("PublicIp", stackName)
 	log.Infof("public ip %q is available and assigned to app.%s", *publicIP, domainName)
 	log.Succeed()
-	return publicIP
+	return publicIP, nil
 }
 
-func (session *Session) getStackOutputValue(name string, stackName string) *string {
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
-	description, _ := stack.Describe()
-	result := filterOutputs(description.Outputs, func(output cloudformation.Output) bool {
-		return *output.ExportName == name
+func (session *Session) getStackOutput(stackName string, predicate func(cloudformation.Output) bool) (*string, error) {
+	stack := NewStack(stackName, session)
+	description, err := stack.Describe()
+	if err != nil {
+		return nil, err
+	}
+	if description == nil {
+		return nil, fmt.Errorf("stack %s does not exist", stackName)
+	}
+	result := filterOutputs(description.Outputs, predicate)
+	if len(result) == 0 {
+		return nil, fmt.Errorf("stack %s does not have the expected output", stackName)
+	}
+	return result[0].OutputValue, nil
+}
+
+func (session *Session) getStackOutputValue(name string, stackName string) (*string, error) {
+	return session.getStackOutput(stackName, func(output cloudformation.Output) bool {
+		return output.ExportName != nil && *output.ExportName == name
 	})
-	return result[0].OutputValue
 }
 
-func (session *Session) getStackOutputValueByKey(name string, stackName string) *string {
-	cfnClient := cloudformation.New(session.config)
-	stack := &Stack{name: stackName, api: cfnClient}
-	description, _ := stack.Describe()
-	result := filterOutputs(description.Outputs, func(output cloudformation.Output) bool {
-		return *output.OutputKey == name
+func (session *Session) getStackOutputValueByKey(name string, stackName string) (*string, error) {
+	return session.getStackOutput(stackName, func(output cloudformation.Output) bool {
+		return output.OutputKey != nil && *output.OutputKey == name
 	})
-	return result[0].OutputValue
 }
 
-func wait(resultChannel chan *waitResult, predicate func() (*string, error)) {
+func wait(done <-chan struct{}, resultChannel chan *waitResult, predicate func() (*string, error)) {
 	ticker := time.NewTicker(5 * time.Second)
 	allowedRetries := 3
 	defer func() {
@@ -85,7 +109,7 @@ func wait(resultChannel chan *waitResult, predicate func() (*string, error)) {
 	}()
 	for {
 		select {
-		case <-ctx.GetContext().Done():
+		case <-done:
 			return
 		case <-ticker.C:
 			result, err := predicate()
@@ -97,6 +121,7 @@ func wait(resultChannel chan *waitResult, predicate func() (*string, error)) {
 					err:    err,
 					output: nil,
 				}
+				return
 			}
 			if result != nil {
 				resultChannel <- &waitResult{
@@ -109,95 +134,286 @@ func wait(resultChannel chan *waitResult, predicate func() (*string, error)) {
 	}
 }
 
-func (session *Session) addEcsInstance() *string {
+// updateEcsSpotFleet applies an instance type or spot price that changed since the spot fleet was created,
+// cloudformation replaces the spot fleet request and its instances when they change
+func (session *Session) updateEcsSpotFleet(envName string, domainName string, fleet types.FleetParameters) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
+	stackName := session.GetComputeConfig().EcsSpotFleetStackName
+	stack := NewStack(stackName, session)
+	description, err := stack.Describe()
+	if err != nil {
+		err = types.NewAwsError("get spot fleet details", err)
+		log.Fail(err)
+		return err
+	}
+	if description == nil {
+		err = types.NewUserError("get spot fleet details", fmt.Errorf("stack %s does not exist, run init first", stackName))
+		log.Fail(err)
+		return err
+	}
+	current := map[string]string{}
+	for _, parameter := range description.Parameters {
+		current[aws.StringValue(parameter.ParameterKey)] = aws.StringValue(parameter.ParameterValue)
+	}
+	if (fleet.InstanceType == "" || current["InstanceType"] == fleet.InstanceType) &&
+		(fleet.SpotPrice == "" || current["SpotPrice"] == fleet.SpotPrice) {
+		log.Succeedf("Spot fleet uses %s instances at up to %s", current["InstanceType"], current["SpotPrice"])
+		return nil
+	}
+	log.Infof("changing spot fleet from %s at up to %s...", current["InstanceType"], current["SpotPrice"])
+	err = stack.Update("spotFleet.yml", session.getSpotFleetParameters(envName, domainName, fleet))
+	if err != nil {
+		err = types.NewAwsError("update spot fleet", err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
 
-	cfg := session.GetComputeConfig()
-	stackName := cfg.EcsSpotFleetStackName
+// getActiveInstanceIDs returns the instances the spot fleet request is running or starting
+func (session *Session) getActiveInstanceIDs(spotFleetRequestID *string) ([]string, error) {
+	request := ec2.New(session.config).DescribeSpotFleetInstancesRequest(&ec2.DescribeSpotFleetInstancesInput{
+		SpotFleetRequestId: spotFleetRequestID,
+	})
+	response, err := request.Send(session.getContext())
+	if err != nil {
+		return nil, err
+	}
+	var instanceIDs []string
+	for _, instance := range response.ActiveInstances {
+		instanceIDs = append(instanceIDs, aws.StringValue(instance.InstanceId))
+	}
+	return instanceIDs, nil
+}
 
-	log.Info("get spot fleet details...")
-	spotFleetRequestID := session.getStackOutputValue(
-		fmt.Sprintf("%s-spotfleetrequest", stackName),
-		stackName)
+// capacityTimeout is how long start waits for the spot fleet to reach its target capacity
+const capacityTimeout = 10 * time.Minute
 
+// getSpotFleetErrors returns the errors the spot fleet request reported since the time, e.g. a spot price below the market price
+func (session *Session) getSpotFleetErrors(spotFleetRequestID *string, since time.Time) ([]string, error) {
 	api := ec2.New(session.config)
-	describeRequest := api.DescribeSpotFleetInstancesRequest(&ec2.DescribeSpotFleetInstancesInput{
+	input := &ec2.DescribeSpotFleetRequestHistoryInput{
 		SpotFleetRequestId: spotFleetRequestID,
-	})
+		EventType:          ec2.EventTypeError,
+		StartTime:          aws.Time(since),
+	}
+	var errors []string
+	for {
+		response, err := api.DescribeSpotFleetRequestHistoryRequest(input).Send(session.getContext())
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range response.HistoryRecords {
+			errors = append(errors, strings.TrimSuffix(describeHistoryRecord(record), "\n"))
+		}
+		if response.NextToken == nil {
+			return errors, nil
+		}
+		input.NextToken = response.NextToken
+	}
+}
 
-	descriptionResponse, err := describeRequest.Send(ctx.GetContext())
+// capacityNotReachedError explains why the spot fleet did not reach the capacity with the errors it reported
+func (session *Session) capacityNotReachedError(spotFleetRequestID *string, capacity int64, since time.Time) error {
+	reason := "the spot fleet reported no errors"
+	errors, err := session.getSpotFleetErrors(spotFleetRequestID, since)
 	if err != nil {
-		log.Fail("fail to get spot fleet instance")
-		return nil
+		reason = fmt.Sprintf("its errors could not be read, %v", err)
+	} else if len(errors) > 0 {
+		reason = strings.Join(errors, ", ")
 	}
+	return types.NewAwsError("allocate new ECS instance",
+		fmt.Errorf("the spot fleet did not reach %d instances within %v, %s, "+
+			"try a higher --spot-price, another --instance-type or a lower --capacity", capacity, capacityTimeout, reason))
+}
 
-	if len(descriptionResponse.ActiveInstances) > 0 {
-		log.Info("there's already one instance")
-		log.Succeed()
-		return descriptionResponse.ActiveInstances[0].InstanceId
+// forgetReplacedInstances runs the instance steps again when the instances recorded by the previous run
+// are no longer all active in the spot fleet, e.g. after a spot interruption replaced one of them
+func (session *Session) forgetReplacedInstances(workflow *journal.Workflow) {
+	outputs, completed := workflow.Completed("ecsInstances")
+	if !completed {
+		return
 	}
+	log := logger.New()
+	defer log.LogDone()
+	stackName := session.GetComputeConfig().EcsSpotFleetStackName
+	spotFleetRequestID, err := session.getStackOutputValue(fmt.Sprintf("%s-spotfleetrequest", stackName), stackName)
+	var activeInstanceIDs []string
+	if err == nil {
+		activeInstanceIDs, err = session.getActiveInstanceIDs(spotFleetRequestID)
+	}
+	if err != nil {
+		log.Debugf("cannot check the instances recorded by the previous run, %v", err)
+		return
+	}
+	active := map[string]bool{}
+	for _, instanceID := range activeInstanceIDs {
+		active[instanceID] = true
+	}
+	for _, instanceID := range strings.Split(outputs["instanceIds"], ",") {
+		if active[instanceID] {
+			continue
+		}
+		log.Infof("instance %s recorded by the previous run is no longer active in the spot fleet, adding the instances again", instanceID)
+		if err := workflow.Forget("ecsInstances", "instancesRunning", "attachPublicIp"); err != nil {
+			log.Debugf("fail to save %s, %v", session.journal.Path(), err)
+		}
+		return
+	}
+}
 
-	log.Info("starting ecs instance...")
-	modificationRequest := api.ModifySpotFleetRequestRequest(&ec2.ModifySpotFleetRequestInput{
-		SpotFleetRequestId: spotFleetRequestID,
-		TargetCapacity:     aws.Int64(1),
-	})
+// addEcsInstances sets the spot fleet target capacity and waits till the spot fleet runs that many instances
+func (session *Session) addEcsInstances(capacity int64) ([]string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	cfg := session.GetComputeConfig()
+	stackName := cfg.EcsSpotFleetStackName
 
-	modificationResponse, err := modificationRequest.Send(ctx.GetContext())
+	log.Info("get spot fleet details...")
+	spotFleetRequestID, err := session.getStackOutputValue(
+		fmt.Sprintf("%s-spotfleetrequest", stackName),
+		stackName)
 	if err != nil {
-		log.Fail("fail to allocate new ECS instance")
-		return nil
+		err = types.NewAwsError("get spot fleet details", err)
+		log.Fail(err)
+		return nil, err
 	}
 
-	if !*modificationResponse.Return {
-		log.Debug(modificationResponse.String())
-		log.Failf("fail to allocate new ECS instance")
-		return nil
+	instanceIDs, err := session.getActiveInstanceIDs(spotFleetRequestID)
+	if err != nil {
+		err = types.NewAwsError("get spot fleet instance", err)
+		log.Fail(err)
+		return nil, err
 	}
 
-	log.Info("waiting for ecs instance to be running...")
-	c := make(chan *waitResult)
-	go wait(c, func() (*string, error) {
-		request := api.DescribeSpotFleetInstancesRequest(&ec2.DescribeSpotFleetInstancesInput{
+	if int64(len(instanceIDs)) == capacity {
+		log.Infof("there's already %d instances", capacity)
+	} else {
+		log.Infof("changing the number of ecs instances from %d to %d...", len(instanceIDs), capacity)
+		api := ec2.New(session.config)
+		modificationRequest := api.ModifySpotFleetRequestRequest(&ec2.ModifySpotFleetRequestInput{
 			SpotFleetRequestId: spotFleetRequestID,
+			TargetCapacity:     aws.Int64(capacity),
 		})
 
-		response, err := request.Send(ctx.GetContext())
+		modificationResponse, err := modificationRequest.Send(session.getContext())
 		if err != nil {
+			err = types.NewAwsError("allocate new ECS instance", err)
+			log.Fail(err)
 			return nil, err
 		}
 
-		if len(response.ActiveInstances) > 0 {
-			return response.ActiveInstances[0].InstanceId, nil
+		if !*modificationResponse.Return {
+			log.Debug(modificationResponse.String())
+			err = types.NewAwsError("allocate new ECS instance", fmt.Errorf("the spot fleet request was not modified"))
+			log.Fail(err)
+			return nil, err
+		}
+
+		log.Info("waiting for ecs instances to be running...")
+		modifiedAt := time.Now()
+		waitCtx, cancel := context.WithTimeout(session.getContext(), capacityTimeout)
+		defer cancel()
+		c := make(chan *waitResult)
+		go wait(waitCtx.Done(), c, func() (*string, error) {
+			instanceIDs, err := session.getActiveInstanceIDs(spotFleetRequestID)
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(instanceIDs)) == capacity {
+				return aws.String(strings.Join(instanceIDs, ",")), nil
+			}
+			return nil, nil
+		})
+		result := <-c
+		if result != nil && result.err != nil {
+			err = types.NewAwsError("get spot fleet information", result.err)
+			log.Fail(err)
+			return nil, err
+		}
+		if result == nil && waitCtx.Err() == context.DeadlineExceeded {
+			err = session.capacityNotReachedError(spotFleetRequestID, capacity, modifiedAt)
+			log.Fail(err)
+			return nil, err
+		}
+		if result == nil {
+			err = types.NewAwsError("get spot fleet information", fmt.Errorf("the active instances were not reported"))
+			log.Fail(err)
+			return nil, err
+		}
+		instanceIDs = strings.Split(*result.output, ",")
+	}
+
+	err = ec2.New(session.config).WaitUntilSystemStatusOk(session.getContext(), &ec2.DescribeInstanceStatusInput{
+		InstanceIds: instanceIDs,
+	})
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("wait for %s to reach OK status", strings.Join(instanceIDs, ", ")), err)
+		log.Fail(err)
+		return nil, err
+	}
+
+	log.Succeedf("instances %s started", strings.Join(instanceIDs, ", "))
+	return instanceIDs, nil
+}
+
+// findFrontProxyInstance picks the instance the front proxy runs on, the public ip is attached to it,
+// the first instance is used when the front proxy is not deployed or not placed in time
+func (session *Session) findFrontProxyInstance(instanceIDs []string) (string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	if len(instanceIDs) == 1 {
+		log.Succeed()
+		return instanceIDs[0], nil
+	}
+	clusterName := session.GetComputeConfig().EcsClusterName
+	serviceName := string(cmdTypes.KnownAppFrontProxy)
+
+	waitCtx, cancel := context.WithTimeout(session.getContext(), frontProxyPlacementTimeout)
+	defer cancel()
+	log.Info("waiting for the front proxy to be placed...")
+	c := make(chan *waitResult)
+	go wait(waitCtx.Done(), c, func() (*string, error) {
+		hostIDs, err := session.getServiceInstanceIDs(serviceName, clusterName)
+		if err != nil {
+			return nil, err
+		}
+		if hostIDs == nil {
+			// the front proxy is not deployed
+			return aws.String(""), nil
+		}
+		for _, hostID := range hostIDs {
+			for _, instanceID := range instanceIDs {
+				if hostID == instanceID {
+					return aws.String(hostID), nil
+				}
+			}
 		}
 		return nil, nil
 	})
 	result := <-c
 	if result != nil && result.err != nil {
-		log.Debug(result.err.Error())
-		log.Fail("fail to get spot fleet information")
-		return nil
+		err := types.NewAwsError("find the front proxy instance", result.err)
+		log.Fail(err)
+		return "", err
 	}
-	if result == nil {
-		log.Fail("get spot fleet returns empty information")
-		return nil
+	if err := session.getContext().Err(); err != nil {
+		err = types.NewError("find the front proxy instance", err)
+		log.Fail(err)
+		return "", err
 	}
-	instanceId := *result.output
-	err = api.WaitUntilSystemStatusOk(ctx.GetContext(), &ec2.DescribeInstanceStatusInput{
-		InstanceIds: []string{instanceId},
-	})
-
-	if err != nil {
-		log.Failf("waiting for %s to reach OK status failed", instanceId)
-		return nil
+	if result == nil || *result.output == "" {
+		log.Succeedf("front proxy is not running, using %s", instanceIDs[0])
+		return instanceIDs[0], nil
 	}
-
-	log.Succeedf("instanceId %q started", instanceId)
-	return result.output
+	log.Succeedf("front proxy runs on %s", *result.output)
+	return *result.output, nil
 }
 
-func (session *Session) attachPublicIPToEcsInstance(publicIP *string, instanceID *string) {
+func (session *Session) attachPublicIPToEcsInstance(publicIP *string, instanceID *string) (*string, error) {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	api := ec2.New(session.config)
@@ -205,52 +421,138 @@ func (session *Session) attachPublicIPToEcsInstance(publicIP *string, instanceID
 	request := api.AssociateAddressRequest(&ec2.AssociateAddressInput{
 		InstanceId: instanceID,
 		PublicIp:   publicIP,
+		// the address may still be attached to an interrupted instance that is shutting down
+		AllowReassociation: aws.Bool(true),
 	})
-	response, err := request.Send(ctx.GetContext())
+	response, err := request.Send(session.getContext())
 	if err != nil {
-		log.Failf("fail to attach %q to %q", publicIP, instanceID)
-		return
+		err = types.NewAwsError(fmt.Sprintf("attach %q to %q", *publicIP, *instanceID), err)
+		log.Fail(err)
+		return nil, err
 	}
 	log.Debugf("associationId id=%q", *response.AssociationId)
+	log.Result("publicIp", *publicIP)
+	log.Result("instanceId", *instanceID)
 	log.Succeed()
+	return response.AssociationId, nil
 }
 
-func (session *Session) waitTillInstanceRunning(instanceID string) {
+func (session *Session) waitTillInstancesRunning(instanceIDs []string) error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	api := ec2.New(session.config)
 
 	c := make(chan *waitResult)
-	go wait(c, func() (*string, error) {
+	go wait(session.getContext().Done(), c, func() (*string, error) {
 		request := api.DescribeInstanceStatusRequest(&ec2.DescribeInstanceStatusInput{
-			InstanceIds: []string{instanceID},
+			InstanceIds: instanceIDs,
 		})
-		response, err := request.Send(ctx.GetContext())
+		response, err := request.Send(session.getContext())
 		if err != nil {
 			return nil, err
 		}
 
-		if response.InstanceStatuses[0].InstanceState.Name == ec2.InstanceStateNameRunning {
+		running := 0
+		for _, instanceStatus := range response.InstanceStatuses {
+			if instanceStatus.InstanceState.Name == ec2.InstanceStateNameRunning {
+				running++
+			}
+		}
+		if running == len(instanceIDs) {
 			return aws.String(string(ec2.InstanceStateNameRunning)), nil
 		}
 
 		return nil, nil
 	})
 	result := <-c
+	if result == nil {
+		err := types.NewError("wait for instances to be running", session.getContext().Err())
+		log.Fail(err)
+		return err
+	}
 	if result.err != nil {
-		log.Debug(result.err.Error())
-		log.Fail("unexpected error while waiting for instance to be running")
-		return
+		err := types.NewAwsError("wait for instances to be running", result.err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
+	return nil
 }
 
-// Start starts up the required compute and public interface
-func (session *Session) Start(parameters *types.StartParameters) {
+// Start starts up the required compute and public interface and schedules its shutdown,
+// steps completed by an interrupted run are skipped
+func (session *Session) Start(parameters *types.StartParameters) error {
 	hostedZoneName := parameters.HostedZoneName
 	domainName := parameters.DomainName
-	publicIP := session.createPublicIP(hostedZoneName, domainName)
-	instanceID := session.addEcsInstance()
-	session.waitTillInstanceRunning(*instanceID)
-	session.attachPublicIPToEcsInstance(publicIP, instanceID)
+	fleet := parameters.Fleet
+	if fleet.Capacity < 1 {
+		fleet.Capacity = 1
+	}
+	workflow := session.beginWorkflow(startWorkflow, map[string]string{
+		"hostedZoneName": hostedZoneName,
+		"domainName":     domainName,
+		"instanceType":   fleet.InstanceType,
+		"spotPrice":      fleet.SpotPrice,
+		"capacity":       strconv.FormatInt(fleet.Capacity, 10),
+	})
+	if err := session.updateEcsSpotFleet(parameters.EnvironmentName, domainName, fleet); err != nil {
+		return err
+	}
+	// the shutdown is scheduled first so that an instance started by a run that fails is still shut down
+	if parameters.StopAt != nil {
+		if err := session.scheduleShutdown(*parameters.StopAt); err != nil {
+			return err
+		}
+	}
+	publicIPOutputs, err := session.runStep(workflow, "publicIp", func(session *Session) (map[string]string, error) {
+		publicIP, err := session.createPublicIP(hostedZoneName, domainName)
+		if err != nil || publicIP == nil {
+			return nil, err
+		}
+		return map[string]string{"publicIp": *publicIP}, nil
+	})
+	if err != nil {
+		return err
+	}
+	if session.plan {
+		log := logger.New()
+		defer log.LogDone()
+		log.Print(fmt.Sprintf("\nplan: set spot fleet target capacity to %d and attach the public ip to the front proxy instance\n", fleet.Capacity))
+		return nil
+	}
+	publicIP := publicIPOutputs["publicIp"]
+	session.forgetReplacedInstances(workflow)
+	instanceOutputs, err := session.runStep(workflow, "ecsInstances", func(session *Session) (map[string]string, error) {
+		instanceIDs, err := session.addEcsInstances(fleet.Capacity)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"instanceIds": strings.Join(instanceIDs, ",")}, nil
+	})
+	if err != nil {
+		return err
+	}
+	instanceIDs := strings.Split(instanceOutputs["instanceIds"], ",")
+	_, err = session.runStep(workflow, "instancesRunning", func(session *Session) (map[string]string, error) {
+		return nil, session.waitTillInstancesRunning(instanceIDs)
+	})
+	if err != nil {
+		return err
+	}
+	_, err = session.runStep(workflow, "attachPublicIp", func(session *Session) (map[string]string, error) {
+		instanceID, err := session.findFrontProxyInstance(instanceIDs)
+		if err != nil {
+			return nil, err
+		}
+		associationID, err := session.attachPublicIPToEcsInstance(&publicIP, &instanceID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]string{"associationId": *associationID, "instanceId": instanceID}, nil
+	})
+	if err != nil {
+		return err
+	}
+	session.finishWorkflow(workflow)
+	return nil
 }
@@ -0,0 +1,240 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+const notFoundStatus = "NOT_FOUND"
+
+var activeStackStatuses = []cloudformation.StackStatus{
+	cloudformation.StackStatusCreateInProgress,
+	cloudformation.StackStatusCreateFailed,
+	cloudformation.StackStatusCreateComplete,
+	cloudformation.StackStatusRollbackInProgress,
+	cloudformation.StackStatusRollbackFailed,
+	cloudformation.StackStatusRollbackComplete,
+	cloudformation.StackStatusDeleteInProgress,
+	cloudformation.StackStatusDeleteFailed,
+	cloudformation.StackStatusUpdateInProgress,
+	cloudformation.StackStatusUpdateCompleteCleanupInProgress,
+	cloudformation.StackStatusUpdateComplete,
+	cloudformation.StackStatusUpdateRollbackInProgress,
+	cloudformation.StackStatusUpdateRollbackFailed,
+	cloudformation.StackStatusUpdateRollbackCompleteCleanupInProgress,
+	cloudformation.StackStatusUpdateRollbackComplete,
+	cloudformation.StackStatusReviewInProgress,
+}
+
+// isStackAvailable reports whether the stack has been created and its outputs can be used
+func isStackAvailable(status string) bool {
+	switch cloudformation.StackStatus(status) {
+	case cloudformation.StackStatusCreateComplete,
+		cloudformation.StackStatusUpdateComplete,
+		cloudformation.StackStatusUpdateCompleteCleanupInProgress,
+		cloudformation.StackStatusUpdateRollbackComplete:
+		return true
+	}
+	return false
+}
+
+func (session *Session) getStackStatus(role string, stackName string) (types.StackStatus, error) {
+	status := types.StackStatus{Role: role, Name: stackName, Status: notFoundStatus}
+	description, err := NewStack(stackName, session).Describe()
+	if err != nil {
+		return status, err
+	}
+	if description != nil {
+		status.Status = string(description.StackStatus)
+	}
+	return status, nil
+}
+
+func (session *Session) getSpotFleetInstances(spotFleetRequestID *string) ([]types.InstanceStatus, error) {
+	api := ec2.New(session.config)
+	fleetResponse, err := api.DescribeSpotFleetInstancesRequest(&ec2.DescribeSpotFleetInstancesInput{
+		SpotFleetRequestId: spotFleetRequestID,
+	}).Send(session.getContext())
+	if err != nil {
+		return nil, err
+	}
+
+	var instanceIDs []string
+	for _, activeInstance := range fleetResponse.ActiveInstances {
+		instanceIDs = append(instanceIDs, *activeInstance.InstanceId)
+	}
+	if len(instanceIDs) == 0 {
+		return nil, nil
+	}
+
+	instancesResponse, err := api.DescribeInstancesRequest(&ec2.DescribeInstancesInput{
+		InstanceIds: instanceIDs,
+	}).Send(session.getContext())
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []types.InstanceStatus
+	for _, reservation := range instancesResponse.Reservations {
+		for _, instance := range reservation.Instances {
+			instances = append(instances, types.InstanceStatus{
+				InstanceID:   *instance.InstanceId,
+				InstanceType: string(instance.InstanceType),
+				State:        string(instance.State.Name),
+			})
+		}
+	}
+	return instances, nil
+}
+
+func (session *Session) getPublicIPStatus(publicIP *string, domainName string) (*types.PublicIPStatus, error) {
+	api := ec2.New(session.config)
+	response, err := api.DescribeAddressesRequest(&ec2.DescribeAddressesInput{
+		PublicIps: []string{*publicIP},
+	}).Send(session.getContext())
+	if err != nil {
+		return nil, err
+	}
+
+	status := &types.PublicIPStatus{PublicIP: *publicIP, DomainName: domainName}
+	if len(response.Addresses) > 0 && response.Addresses[0].InstanceId != nil {
+		status.InstanceID = *response.Addresses[0].InstanceId
+	}
+	return status, nil
+}
+
+func (session *Session) getAppStatuses() ([]types.AppStatus, error) {
+	config := session.GetComputeConfig()
+	api := cloudformation.New(session.config)
+	paginator := cloudformation.NewListStacksPaginator(api.ListStacksRequest(&cloudformation.ListStacksInput{
+		StackStatusFilter: activeStackStatuses,
+	}))
+
+	var apps []types.AppStatus
+	for paginator.Next(session.getContext()) {
+		for _, summary := range paginator.CurrentPage().StackSummaries {
+			stackName := *summary.StackName
+			if !strings.HasPrefix(stackName, config.AppStackPrefix) {
+				continue
+			}
+			apps = append(apps, types.AppStatus{
+				Name:        strings.TrimPrefix(stackName, config.AppStackPrefix),
+				StackName:   stackName,
+				StackStatus: string(summary.StackStatus),
+			})
+		}
+	}
+	if err := paginator.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, app := range apps {
+		response, err := session.DescribeService(app.Name, config.EcsClusterName)
+		if err != nil {
+			return nil, err
+		}
+		if len(response.Services) == 0 {
+			continue
+		}
+		service := response.Services[0]
+		if service.RunningCount != nil {
+			apps[i].RunningCount = *service.RunningCount
+		}
+		if service.DesiredCount != nil {
+			apps[i].DesiredCount = *service.DesiredCount
+		}
+	}
+	return apps, nil
+}
+
+func (session *Session) collectStatus(parameters *types.StatusParameters) (*types.EnvironmentStatus, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	config := session.GetComputeConfig()
+	status := &types.EnvironmentStatus{EnvironmentName: parameters.EnvironmentName}
+
+	log.Info("getting stack details...")
+	stacksByRole := [][]string{
+		{"vpc", config.VpcStackName},
+		{"cluster", config.EcsClusterStackName},
+		{"spot-fleet", config.EcsSpotFleetStackName},
+		{"public-ip", config.PublicIPStackName},
+		{"shutdown", config.ShutdownStackName},
+		{"reattach", config.ReattachStackName},
+	}
+	for _, roleAndName := range stacksByRole {
+		stackStatus, err := session.getStackStatus(roleAndName[0], roleAndName[1])
+		if err != nil {
+			err = types.NewAwsError(fmt.Sprintf("get status of stack %q", roleAndName[1]), err)
+			log.Fail(err)
+			return nil, err
+		}
+		status.Stacks = append(status.Stacks, stackStatus)
+	}
+	spotFleetStack, publicIPStack := status.Stacks[2], status.Stacks[3]
+
+	if isStackAvailable(spotFleetStack.Status) {
+		log.Info("getting spot fleet instances...")
+		spotFleetRequestID, err := session.getStackOutputValue(
+			fmt.Sprintf("%s-spotfleetrequest", spotFleetStack.Name),
+			spotFleetStack.Name)
+		if err != nil {
+			err = types.NewAwsError("get spot fleet details", err)
+			log.Fail(err)
+			return nil, err
+		}
+		instances, err := session.getSpotFleetInstances(spotFleetRequestID)
+		if err != nil {
+			err = types.NewAwsError("get spot fleet instances", err)
+			log.Fail(err)
+			return nil, err
+		}
+		status.Instances = instances
+	}
+
+	if isStackAvailable(publicIPStack.Status) {
+		log.Info("getting public ip details...")
+		publicIP, err := session.getStackOutputValueByKey("PublicIp", publicIPStack.Name)
+		if err != nil {
+			err = types.NewAwsError("get public ip details", err)
+			log.Fail(err)
+			return nil, err
+		}
+		// the dns record of the public ip is the one the stack created, not the --domain-name passed to status
+		domainName, err := NewStack(publicIPStack.Name, session).GetParameterValue("DomainName")
+		if err != nil {
+			err = types.NewAwsError("get public ip details", err)
+			log.Fail(err)
+			return nil, err
+		}
+		publicIPStatus, err := session.getPublicIPStatus(publicIP, aws.StringValue(domainName))
+		if err != nil {
+			err = types.NewAwsError("get public ip details", err)
+			log.Fail(err)
+			return nil, err
+		}
+		status.PublicIP = publicIPStatus
+	}
+
+	log.Info("getting app details...")
+	apps, err := session.getAppStatuses()
+	if err != nil {
+		err = types.NewAwsError("get app details", err)
+		log.Fail(err)
+		return nil, err
+	}
+	status.Apps = apps
+	log.Succeed()
+	return status, nil
+}
+
+// Status reports the stacks, instances, public ip and apps of the environment
+func (session *Session) Status(parameters *types.StatusParameters) (*types.EnvironmentStatus, error) {
+	return session.collectStatus(parameters)
+}
@@ -4,21 +4,28 @@ import (
 	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/kahgeh/devenv/logger"
-	"github.com/kahgeh/devenv/utils/ctx"
+	"github.com/kahgeh/devenv/provider/types"
 )
 
-func (session *Session) removeEcsInstance() {
+func (session *Session) removeEcsInstance() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
 	stackName := config.EcsSpotFleetStackName
+	if session.skipWhenPlanning(log, "set spot fleet target capacity to 0") {
+		return nil
+	}
 
-	spotFleetRequestID := session.getStackOutputValue(
+	spotFleetRequestID, err := session.getStackOutputValue(
 		fmt.Sprintf("%s-spotfleetrequest", stackName),
 		stackName)
+	if err != nil {
+		err = types.NewAwsError("get spot fleet details", err)
+		log.Fail(err)
+		return err
+	}
 
 	api := ec2.New(session.config)
 	modificationRequest := api.ModifySpotFleetRequestRequest(&ec2.ModifySpotFleetRequestInput{
@@ -26,36 +33,43 @@ func (session *Session) removeEcsInstance() {
 		TargetCapacity:     aws.Int64(0),
 	})
 
-	_, err := modificationRequest.Send(ctx.GetContext())
+	_, err = modificationRequest.Send(session.getContext())
 	if err != nil {
-		log.Fail("failed to remove instance")
-		return
+		err = types.NewAwsError("remove instance", err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
-
+	return nil
 }
 
-func (session *Session) deletePublicIP() {
+func (session *Session) deletePublicIP() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
 	stackName := config.PublicIPStackName
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
 	stack := NewStack(stackName, session)
-	api := stack.api
-	stack.Delete()
-	err := api.WaitUntilStackDeleteComplete(ctx.GetContext(), &cloudformation.DescribeStacksInput{
-		StackName: &stack.name,
-	})
-	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("fail to wait for public ip deletion")
-		return
+	if _, err := stack.Delete(); err != nil {
+		err = types.NewAwsError("delete public ip", err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
+	return nil
 }
 
 // Stop terminates the ecs instance and remove any attached resources
-func (session *Session) Stop() {
-	session.removeEcsInstance()
-	session.deletePublicIP()
+func (session *Session) Stop() error {
+	// the instance and public ip recorded by an interrupted start no longer exist
+	session.forgetWorkflows(startWorkflow)
+	if err := session.removeEcsInstance(); err != nil {
+		return err
+	}
+	if err := session.deletePublicIP(); err != nil {
+		return err
+	}
+	return session.deleteShutdownSchedule()
 }
@@ -4,35 +4,37 @@ import (
 	"fmt"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/awserr"
-	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/kahgeh/devenv/logger"
-	"github.com/kahgeh/devenv/utils/ctx"
+	"github.com/kahgeh/devenv/provider/types"
 	"os"
 )
 
-func (session *Session) deleteKeyPair() {
+func (session *Session) deleteKeyPair() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 
 	keyPairName := session.GetKeyPairName()
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete key pair %q", keyPairName)) {
+		return nil
+	}
 	api := ec2.New(session.config)
 	request := api.DeleteKeyPairRequest(&ec2.DeleteKeyPairInput{
 		KeyName: aws.String(keyPairName),
 	})
-	_, err := request.Send(ctx.GetContext())
+	_, err := request.Send(session.getContext())
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			if aerr.Code() == "InvalidKeyPair.NotFound" {
 				log.Infof("Key pair %q does not exists", keyPairName)
-				return
+				log.Succeed()
+				return nil
 			}
 			log.Debug(aerr.Message())
-			log.Fail(aerr.Code())
-			return
 		}
-		log.Failf("%v", err)
-		return
+		err = types.NewAwsError(fmt.Sprintf("delete key pair %q", keyPairName), err)
+		log.Fail(err)
+		return err
 	}
 
 	sshFolderPath := getSshFolderPath()
@@ -41,67 +43,88 @@ func (session *Session) deleteKeyPair() {
 	if stat, _ := os.Stat(sshPrivateKeyFilePath); stat != nil {
 		err := os.Remove(sshPrivateKeyFilePath)
 		if err != nil {
-			log.Failf("fail to delete %s", sshPrivateKeyFilePath)
+			err = types.NewError(fmt.Sprintf("delete %s", sshPrivateKeyFilePath), err)
+			log.Fail(err)
+			return err
 		}
 	}
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) deleteVpc() {
+func (session *Session) deleteVpc() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
 	stackName := config.VpcStackName
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
 	stack := NewStack(stackName, session)
-	stack.Delete()
+	if _, err := stack.Delete(); err != nil {
+		err = types.NewAwsError("delete vpc", err)
+		log.Fail(err)
+		return err
+	}
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) deleteEcsCluster() {
+func (session *Session) deleteEcsCluster() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
 	stackName := config.EcsClusterStackName
-
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
 	stack := NewStack(stackName, session)
-	api := stack.api
-	stack.Delete()
-	err := api.WaitUntilStackDeleteComplete(ctx.GetContext(), &cloudformation.DescribeStacksInput{
-		StackName: &stack.name,
-	})
-
-	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("waiting ecs cluster deletion failed")
-		return
+	if _, err := stack.Delete(); err != nil {
+		err = types.NewAwsError("delete ecs cluster", err)
+		log.Fail(err)
+		return err
 	}
-
 	log.Succeed()
+	return nil
 }
 
-func (session *Session) deleteSpotFleet() {
+func (session *Session) deleteSpotFleet() error {
 	log := logger.NewTaskLogger()
 	defer log.LogDone()
 	config := session.GetComputeConfig()
 	stackName := config.EcsSpotFleetStackName
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
 	stack := NewStack(stackName, session)
-	api := stack.api
-	stack.Delete()
-	err := api.WaitUntilStackDeleteComplete(ctx.GetContext(), &cloudformation.DescribeStacksInput{
-		StackName: &stack.name,
-	})
-	if err != nil {
-		log.Debug(err.Error())
-		log.Fail("waiting spot fleet deletion failed")
-		return
+	if _, err := stack.Delete(); err != nil {
+		err = types.NewAwsError("delete spot fleet", err)
+		log.Fail(err)
+		return err
 	}
 	log.Succeed()
+	return nil
 }
 
 // Delete tears down the compute
-func (session *Session) Delete() {
-	session.deleteSpotFleet()
-	session.deleteEcsCluster()
-	session.deleteVpc()
-	session.deleteKeyPair()
+func (session *Session) Delete() error {
+	if err := session.deleteShutdownSchedule(); err != nil {
+		return err
+	}
+	if err := session.deleteReattachRule(); err != nil {
+		return err
+	}
+	if err := session.deleteSpotFleet(); err != nil {
+		return err
+	}
+	if err := session.deleteEcsCluster(); err != nil {
+		return err
+	}
+	if err := session.deleteVpc(); err != nil {
+		return err
+	}
+	if err := session.deleteKeyPair(); err != nil {
+		return err
+	}
+	return session.removeJournal()
 }
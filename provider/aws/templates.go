@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	templates "github.com/kahgeh/devenv/aws"
+	"github.com/kahgeh/devenv/fixed"
+	"github.com/kahgeh/devenv/logger"
+)
+
+const frontProxyFolder = "front-proxy"
+
+func getTemplateOverridePath(fileName string) string {
+	return fmt.Sprintf("%s/aws/%s", fixed.GetConfigFolderPath(), fileName)
+}
+
+// reportedOverrides holds the overrides that were reported, each is reported once
+var reportedOverrides sync.Map
+
+// getTemplateParameters returns the names of the parameters of a cloudformation template, the template is not parsed
+// as yaml because the templates rendered with text/template are not yaml before they are rendered
+func getTemplateParameters(content []byte) []string {
+	var names []string
+	inParameters := false
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			inParameters = line == "Parameters:"
+			continue
+		}
+		if inParameters && strings.HasPrefix(line, "  ") && !strings.HasPrefix(line, "   ") && strings.HasSuffix(line, ":") {
+			names = append(names, strings.TrimSuffix(strings.TrimSpace(line), ":"))
+		}
+	}
+	return names
+}
+
+// getMissingParameters returns the parameters of the built in template that the override does not declare
+func getMissingParameters(override []byte, builtIn []byte) []string {
+	declared := map[string]bool{}
+	for _, name := range getTemplateParameters(override) {
+		declared[name] = true
+	}
+	var missing []string
+	for _, name := range getTemplateParameters(builtIn) {
+		if !declared[name] {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// reportOverride tells which override is used, with a warning when it lacks parameters devenv passes to the built in
+// template, e.g. a copy downloaded by init config of an earlier version, cloudformation rejects such a template
+func reportOverride(fileName string, overridePath string, content []byte) {
+	if _, reported := reportedOverrides.LoadOrStore(overridePath, true); reported {
+		return
+	}
+	log := logger.New()
+	defer log.LogDone()
+	log.Print(fmt.Sprintf("using %s instead of the built in %s\n", overridePath, fileName))
+	builtIn, err := fs.ReadFile(templates.Files, fileName)
+	if err != nil || path.Ext(fileName) != ".yml" {
+		return
+	}
+	if missing := getMissingParameters(content, builtIn); len(missing) > 0 {
+		log.Print(fmt.Sprintf("warning: %s does not declare the parameters %s of the built in template, it may have "+
+			"been downloaded by an earlier version, run devenv templates export --overwrite and apply your changes again\n",
+			overridePath, strings.Join(missing, ", ")))
+	}
+}
+
+// readTemplateFile reads the file from ~/.devenv/aws when it has been overridden there, otherwise the embedded one
+func readTemplateFile(fileName string) ([]byte, error) {
+	overridePath := getTemplateOverridePath(fileName)
+	content, err := ioutil.ReadFile(overridePath)
+	if err == nil {
+		reportOverride(fileName, overridePath, content)
+		return content, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return fs.ReadFile(templates.Files, fileName)
+}
+
+// renderTemplate executes the template, quote writes a value as a yaml string
+func renderTemplate(fileName string, content []byte, data interface{}) ([]byte, error) {
+	parsed, err := template.New(fileName).Funcs(template.FuncMap{
+		// a json string is a valid yaml double quoted string
+		"quote": func(value string) (string, error) {
+			quoted, err := json.Marshal(value)
+			return string(quoted), err
+		},
+	}).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+	var rendered bytes.Buffer
+	if err := parsed.Execute(&rendered, data); err != nil {
+		return nil, err
+	}
+	return rendered.Bytes(), nil
+}
+
+// materialiseFrontProxy writes the front proxy assets to a temporary folder to be used as the docker build context,
+// call the returned function to remove the folder once done
+func materialiseFrontProxy() (string, func(), error) {
+	folderPath, err := ioutil.TempDir("", "devenv-front-proxy")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanUp := func() { _ = os.RemoveAll(folderPath) }
+
+	entries, err := fs.ReadDir(templates.Files, frontProxyFolder)
+	if err != nil {
+		cleanUp()
+		return "", nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		content, err := readTemplateFile(path.Join(frontProxyFolder, entry.Name()))
+		if err != nil {
+			cleanUp()
+			return "", nil, err
+		}
+		if err := ioutil.WriteFile(filepath.Join(folderPath, entry.Name()), content, 0644); err != nil {
+			cleanUp()
+			return "", nil, err
+		}
+	}
+	return folderPath, cleanUp, nil
+}
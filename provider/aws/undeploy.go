@@ -0,0 +1,155 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/awserr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+func (session *Session) deleteApp(appName string) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	stackName := session.GetComputeConfig().GetAppStackName(appName)
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
+	if _, err := NewStack(stackName, session).Delete(); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("delete %s", appName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+// deleteAppParameters deletes the parameters the app stack saved, the secrets are kept for the next deploy
+func (session *Session) deleteAppParameters(envName string, appName string) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	path := fmt.Sprintf("/allEnvs/%s/apps/%s", envName, appName)
+	ssmSession := session.NewSsmSession()
+	allNames, err := ssmSession.GetParameterNamesByPath(path)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("list parameters under %q", path), err)
+		log.Fail(err)
+		return err
+	}
+	secretsPath := getSecretsPath(envName, appName) + "/"
+	var names []string
+	for _, name := range allNames {
+		if !strings.HasPrefix(name, secretsPath) {
+			names = append(names, name)
+		}
+	}
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete %v parameters under %q", len(names), path)) {
+		return nil
+	}
+	if err := ssmSession.DeleteParameters(names); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("delete parameters under %q", path), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeedf("Deleted %v parameters under %q", len(names), path)
+	return nil
+}
+
+// listImageIds returns every image of the repository, nil when the repository does not exist
+func (session *Session) listImageIds(repositoryName string) ([]ecr.ImageIdentifier, error) {
+	api := ecr.New(session.config)
+	paginator := ecr.NewListImagesPaginator(api.ListImagesRequest(&ecr.ListImagesInput{
+		RepositoryName: aws.String(repositoryName),
+	}))
+	var imageIds []ecr.ImageIdentifier
+	for paginator.Next(session.getContext()) {
+		imageIds = append(imageIds, paginator.CurrentPage().ImageIds...)
+	}
+	if err := paginator.Err(); err != nil {
+		if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == ecr.ErrCodeRepositoryNotFoundException {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return imageIds, nil
+}
+
+func (session *Session) deleteImages(appName string) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	repositoryName := session.GetComputeConfig().GetRepositoryName(appName)
+	imageIds, err := session.listImageIds(repositoryName)
+	if err != nil {
+		err = types.NewAwsError(fmt.Sprintf("list images of %s", repositoryName), err)
+		log.Fail(err)
+		return err
+	}
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete %v images of %s", len(imageIds), repositoryName)) {
+		return nil
+	}
+
+	const batchSize = 100
+	api := ecr.New(session.config)
+	for start := 0; start < len(imageIds); start += batchSize {
+		end := start + batchSize
+		if end > len(imageIds) {
+			end = len(imageIds)
+		}
+		response, err := api.BatchDeleteImageRequest(&ecr.BatchDeleteImageInput{
+			RepositoryName: aws.String(repositoryName),
+			ImageIds:       imageIds[start:end],
+		}).Send(session.getContext())
+		if err == nil {
+			for _, failure := range response.Failures {
+				if failure.FailureCode != ecr.ImageFailureCodeImageNotFound {
+					err = fmt.Errorf(aws.StringValue(failure.FailureReason))
+					break
+				}
+			}
+		}
+		if err != nil {
+			err = types.NewAwsError(fmt.Sprintf("delete images of %s", repositoryName), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeedf("Deleted %v images of %s", len(imageIds), repositoryName)
+	return nil
+}
+
+func (session *Session) deleteRepository(appName string) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	stackName := session.GetComputeConfig().GetEcrStackName(appName)
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
+	if _, err := NewStack(stackName, session).Delete(); err != nil {
+		err = types.NewAwsError(fmt.Sprintf("delete the repository of %s", appName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+// Undeploy removes the app, its parameters and, when purging images, its repository
+func (session *Session) Undeploy(parameters *types.UndeployParameters) error {
+	session.forgetWorkflows(deployWorkflow(parameters.AppName))
+	if err := session.deleteApp(parameters.AppName); err != nil {
+		return err
+	}
+	if err := session.deleteAppParameters(parameters.EnvironmentName, parameters.AppName); err != nil {
+		return err
+	}
+	if !parameters.PurgeImages {
+		return nil
+	}
+	if err := session.deleteImages(parameters.AppName); err != nil {
+		return err
+	}
+	return session.deleteRepository(parameters.AppName)
+}
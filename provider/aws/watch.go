@@ -0,0 +1,238 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+// watchState is what the watch has reported so far, a state is only reported when it changes
+type watchState struct {
+	// historySince is where the next read of the spot fleet history starts
+	historySince time.Time
+	reported     string
+}
+
+func (state *watchState) report(log *logger.Logger, text string) {
+	if state.reported == text {
+		return
+	}
+	state.reported = text
+	log.Print(text)
+}
+
+func describeHistoryRecord(record ec2.HistoryRecord) string {
+	parts := []string{string(record.EventType)}
+	if information := record.EventInformation; information != nil {
+		for _, value := range []*string{information.EventSubType, information.InstanceId, information.EventDescription} {
+			if aws.StringValue(value) != "" {
+				parts = append(parts, aws.StringValue(value))
+			}
+		}
+	}
+	return fmt.Sprintf("%s %s\n", aws.TimeValue(record.Timestamp).Local().Format("15:04:05"), strings.Join(parts, " "))
+}
+
+// reportSpotFleetHistory prints what happened to the spot fleet since the last read, e.g. an interrupted instance being replaced
+func (session *Session) reportSpotFleetHistory(log *logger.Logger, spotFleetRequestID *string, state *watchState) error {
+	api := ec2.New(session.config)
+	input := &ec2.DescribeSpotFleetRequestHistoryInput{
+		SpotFleetRequestId: spotFleetRequestID,
+		StartTime:          aws.Time(state.historySince),
+	}
+	for {
+		response, err := api.DescribeSpotFleetRequestHistoryRequest(input).Send(session.getContext())
+		if err != nil {
+			return err
+		}
+		for _, record := range response.HistoryRecords {
+			log.Print(describeHistoryRecord(record))
+		}
+		if response.LastEvaluatedTime != nil {
+			state.historySince = *response.LastEvaluatedTime
+		}
+		if response.NextToken == nil {
+			return nil
+		}
+		input.NextToken = response.NextToken
+	}
+}
+
+func containsInstance(instanceIDs []string, instanceID string) bool {
+	for _, id := range instanceIDs {
+		if id == instanceID {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPublicIP reattaches the public ip when it is not attached to an active instance of the spot fleet
+func (session *Session) checkPublicIP(log *logger.Logger, state *watchState) error {
+	config := session.GetComputeConfig()
+	spotFleetRequestID, err := session.getStackOutputValue(
+		fmt.Sprintf("%s-spotfleetrequest", config.EcsSpotFleetStackName),
+		config.EcsSpotFleetStackName)
+	if err != nil {
+		return types.NewAwsError("get spot fleet details", err)
+	}
+	if err := session.reportSpotFleetHistory(log, spotFleetRequestID, state); err != nil {
+		return types.NewAwsError("get spot fleet history", err)
+	}
+
+	publicIPStatus, err := session.getStackStatus("public-ip", config.PublicIPStackName)
+	if err != nil {
+		return types.NewAwsError(fmt.Sprintf("get status of stack %q", config.PublicIPStackName), err)
+	}
+	if !isStackAvailable(publicIPStatus.Status) {
+		state.report(log, "the environment is stopped, waiting for it to start\n")
+		return nil
+	}
+	publicIP, err := session.getStackOutputValueByKey("PublicIp", config.PublicIPStackName)
+	if err != nil {
+		return types.NewAwsError("get public ip details", err)
+	}
+	address, err := session.getPublicIPStatus(publicIP, "")
+	if err != nil {
+		return types.NewAwsError("get public ip details", err)
+	}
+	instanceIDs, err := session.getActiveInstanceIDs(spotFleetRequestID)
+	if err != nil {
+		return types.NewAwsError("get spot fleet instance", err)
+	}
+
+	if containsInstance(instanceIDs, address.InstanceID) {
+		state.report(log, fmt.Sprintf("%s is attached to %s\n", *publicIP, address.InstanceID))
+		return nil
+	}
+	interruption := fmt.Sprintf("%s is not attached, the instance was interrupted\n", *publicIP)
+	if address.InstanceID != "" {
+		interruption = fmt.Sprintf("%s is attached to %s which left the spot fleet, the instance was interrupted\n",
+			*publicIP, address.InstanceID)
+	}
+	if len(instanceIDs) == 0 {
+		state.report(log, interruption+"waiting for the spot fleet to replace the instance\n")
+		return nil
+	}
+	state.report(log, interruption)
+
+	if err := session.waitTillInstancesRunning(instanceIDs); err != nil {
+		return err
+	}
+	instanceID, err := session.findFrontProxyInstance(instanceIDs)
+	if err != nil {
+		return err
+	}
+	if _, err := session.attachPublicIPToEcsInstance(publicIP, &instanceID); err != nil {
+		return err
+	}
+	state.report(log, fmt.Sprintf("%s is reattached to %s\n", *publicIP, instanceID))
+	return nil
+}
+
+// deployReattachRule creates or updates the stack whose eventbridge rules reattach the public ip to the instance running
+// the front proxy when an instance of the spot fleet or the front proxy starts running, spot interruption warnings are
+// logged by the same function
+func (session *Session) deployReattachRule() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	config := session.GetComputeConfig()
+
+	stack := NewStack(config.ReattachStackName, session)
+	err := stack.Update("reattach.yml", []cloudformation.Parameter{
+		{
+			ParameterKey:   aws.String("SpotFleetStackName"),
+			ParameterValue: aws.String(config.EcsSpotFleetStackName),
+		},
+		{
+			ParameterKey:   aws.String("PublicIpStackName"),
+			ParameterValue: aws.String(config.PublicIPStackName),
+		},
+		{
+			ParameterKey:   aws.String("ClusterName"),
+			ParameterValue: aws.String(config.EcsClusterName),
+		},
+	})
+	if err != nil {
+		err = types.NewAwsError("deploy the reattach rule", err)
+		log.Fail(err)
+		return err
+	}
+	if session.plan {
+		log.Succeed()
+		return nil
+	}
+	logGroupName, err := session.getStackOutputValueByKey("LogGroupName", config.ReattachStackName)
+	if err != nil {
+		err = types.NewAwsError("get reattach rule details", err)
+		log.Fail(err)
+		return err
+	}
+	log.Result("logGroupName", *logGroupName)
+	log.Succeedf("Public ip reattached to new instances, interruptions are logged to %s", *logGroupName)
+	return nil
+}
+
+// deleteReattachRule removes the rule deployed by watch --deploy-rule
+func (session *Session) deleteReattachRule() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	stackName := session.GetComputeConfig().ReattachStackName
+	stack := NewStack(stackName, session)
+	description, err := stack.Describe()
+	if err != nil {
+		err = types.NewAwsError("get reattach rule details", err)
+		log.Fail(err)
+		return err
+	}
+	if description == nil {
+		log.Skipf("No reattach rule deployed")
+		return nil
+	}
+	if session.skipWhenPlanning(log, fmt.Sprintf("delete stack %q", stackName)) {
+		return nil
+	}
+	if _, err := stack.Delete(); err != nil {
+		err = types.NewAwsError("delete reattach rule", err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+// Watch reattaches the public ip whenever the spot fleet replaces the instance it is attached to and reports
+// the changes of the spot fleet till interrupted, failed checks are reported and retried, or manages a rule that reattaches it without a running watch
+func (session *Session) Watch(parameters *types.WatchParameters) error {
+	if parameters.RemoveRule {
+		return session.deleteReattachRule()
+	}
+	if parameters.DeployRule {
+		return session.deployReattachRule()
+	}
+	log := logger.New()
+	defer log.LogDone()
+	log.Print(fmt.Sprintf("watching %s every %v, interrupt to stop\n", parameters.EnvironmentName, parameters.Interval))
+	state := &watchState{historySince: time.Now()}
+	for {
+		if err := session.checkPublicIP(log, state); err != nil {
+			if session.getContext().Err() != nil {
+				return nil
+			}
+			// a throttled or timed out call is retried on the next check instead of ending the watch
+			state.report(log, fmt.Sprintf("%s %v, retrying in %v\n", time.Now().Format("15:04:05"), err, parameters.Interval))
+			state.reported = ""
+		}
+		select {
+		case <-session.getContext().Done():
+			return nil
+		case <-time.After(parameters.Interval):
+		}
+	}
+}
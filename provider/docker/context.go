@@ -0,0 +1,136 @@
+package docker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/docker/docker/builder/dockerignore"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/fileutils"
+)
+
+const (
+	defaultDockerfile = "Dockerfile"
+	dockerignoreFile  = ".dockerignore"
+)
+
+// readExcludePatterns returns the patterns of the <Dockerfile>.dockerignore beside the Dockerfile, or else of the
+// .dockerignore of the context folder, none when there is neither
+func readExcludePatterns(context string, dockerfile string) ([]string, error) {
+	for _, ignoreFile := range []string{
+		filepath.Join(context, filepath.FromSlash(dockerfile)+dockerignoreFile),
+		filepath.Join(context, dockerignoreFile),
+	} {
+		file, err := os.Open(ignoreFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		patterns, err := dockerignore.ReadAll(file)
+		_ = file.Close()
+		return patterns, err
+	}
+	return nil, nil
+}
+
+// keepBuildFiles stops the patterns from excluding the Dockerfile and .dockerignore, docker needs both to build
+func keepBuildFiles(patterns []string, dockerfile string) []string {
+	if excluded, _ := fileutils.Matches(dockerignoreFile, patterns); excluded {
+		patterns = append(patterns, "!"+dockerignoreFile)
+	}
+	if excluded, _ := fileutils.Matches(dockerfile, patterns); excluded {
+		patterns = append(patterns, "!"+dockerfile)
+	}
+	return patterns
+}
+
+// createBuildContext tars the context folder, less the files its .dockerignore excludes, into a temporary file so
+// that its size is known before it is sent, remove the file once the build is done
+func createBuildContext(context string, dockerfile string) (file *os.File, size int64, err error) {
+	if dockerfile == "" {
+		dockerfile = defaultDockerfile
+	}
+	patterns, err := readExcludePatterns(context, dockerfile)
+	if err != nil {
+		return nil, 0, err
+	}
+	tar, err := archive.TarWithOptions(context, &archive.TarOptions{
+		ExcludePatterns: keepBuildFiles(patterns, dockerfile),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = tar.Close() }()
+
+	file, err = ioutil.TempFile("", "devenv-context-*.tar")
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if err != nil {
+			removeBuildContext(file)
+			file = nil
+		}
+	}()
+	if size, err = io.Copy(file, tar); err != nil {
+		return
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	return
+}
+
+// FingerprintBuildContext hashes the path, size and modification time of the files of the build context, any change
+// to them, committed or not, gives another fingerprint without the files being read
+func FingerprintBuildContext(context string, dockerfile string) (string, error) {
+	if dockerfile == "" {
+		dockerfile = defaultDockerfile
+	}
+	patterns, err := readExcludePatterns(context, dockerfile)
+	if err != nil {
+		return "", err
+	}
+	matcher, err := fileutils.NewPatternMatcher(keepBuildFiles(patterns, dockerfile))
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	err = filepath.Walk(context, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(context, path)
+		if err != nil || relativePath == "." {
+			return err
+		}
+		excluded, err := matcher.Matches(relativePath)
+		if err != nil {
+			return err
+		}
+		if excluded {
+			// an exception, e.g. !folder/file, can bring back a file of an excluded folder
+			if info.IsDir() && !matcher.Exclusions() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		_, err = fmt.Fprintf(hash, "%s %v %d %d\n",
+			filepath.ToSlash(relativePath), info.Mode(), info.Size(), info.ModTime().UnixNano())
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func removeBuildContext(file *os.File) {
+	_ = file.Close()
+	_ = os.Remove(file.Name())
+}
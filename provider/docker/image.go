@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	whale "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/term"
+	units "github.com/docker/go-units"
+	"github.com/kahgeh/devenv/logger"
+	providerTypes "github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+// ResolveDockerfile returns the path of the Dockerfile relative to the context folder, docker only reads
+// Dockerfiles sent with the context
+func ResolveDockerfile(context string, dockerfile string) (string, error) {
+	contextPath, err := filepath.Abs(context)
+	if err != nil {
+		return "", err
+	}
+	dockerfilePath := dockerfile
+	if !filepath.IsAbs(dockerfilePath) {
+		dockerfilePath = filepath.Join(contextPath, dockerfilePath)
+	}
+	relativePath, err := filepath.Rel(contextPath, dockerfilePath)
+	if err != nil || relativePath == ".." || strings.HasPrefix(relativePath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside the build context %s", dockerfile, contextPath)
+	}
+	info, err := os.Stat(dockerfilePath)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a folder", dockerfile)
+	}
+	return filepath.ToSlash(relativePath), nil
+}
+
+// BuildImage builds the docker image found in the context folder and tags it as <appName>:<id>
+func BuildImage(context string, appName string, id string, options providerTypes.BuildOptions) (*string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	client, err := whale.NewClientWithOpts()
+	if err != nil {
+		err = providerTypes.NewDockerError("access docker", err)
+		log.Fail(err)
+		return nil, err
+	}
+
+	buildCtx, size, err := createBuildContext(context, options.Dockerfile)
+	if err != nil {
+		err = providerTypes.NewUserError(fmt.Sprintf("read build context %s", context), err)
+		log.Fail(err)
+		return nil, err
+	}
+	defer removeBuildContext(buildCtx)
+	if options.ContextSizeWarning > 0 && size > options.ContextSizeWarning {
+		log.Print(fmt.Sprintf("\nwarning: the build context %s is %s, over %s, exclude what the image does not need with .dockerignore\n",
+			context, units.HumanSize(float64(size)), units.HumanSize(float64(options.ContextSizeWarning))))
+	}
+	log.Infof("sending build context of %s to docker", units.HumanSize(float64(size)))
+	tag := fmt.Sprintf("%s:%s", appName, id)
+	buildArgs := map[string]*string{}
+	for name, value := range options.BuildArgs {
+		value := value
+		buildArgs[name] = &value
+	}
+	log.Debugf("build %s dockerfile=%q target=%q noCache=%v pull=%v platform=%q",
+		tag, options.Dockerfile, options.Target, options.NoCache, options.Pull, options.Platform)
+	response, err := client.ImageBuild(ctx.GetContext(),
+		buildCtx,
+		types.ImageBuildOptions{
+			Tags:       []string{tag},
+			Dockerfile: options.Dockerfile,
+			Target:     options.Target,
+			BuildArgs:  buildArgs,
+			NoCache:    options.NoCache,
+			PullParent: options.Pull,
+			Platform:   options.Platform,
+		})
+
+	if err != nil {
+		err = providerTypes.NewDockerError("build image", err)
+		log.Fail(err)
+		return nil, err
+	}
+
+	defer utils.CloseReadCloser(response.Body, func(s string) { log.Debug(s) })
+	log.DebugFunc(func() {
+		termFd, isTerm := term.GetFdInfo(os.Stderr)
+		err = jsonmessage.DisplayJSONMessagesStream(response.Body, os.Stderr, termFd, isTerm, nil)
+	}, func() {
+		// the messages are still decoded to find out whether the build failed
+		err = jsonmessage.DisplayJSONMessagesStream(response.Body, ioutil.Discard, 0, false, nil)
+	})
+	if err != nil {
+		err = providerTypes.NewDockerError("build image", err)
+		log.Fail(err)
+		return nil, err
+	}
+	log.Succeed()
+	return &tag, nil
+}
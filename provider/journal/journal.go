@@ -0,0 +1,261 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kahgeh/devenv/fixed"
+)
+
+// Journal records the completed steps of the workflows of an environment, e.g. init, start or deploy/<app>,
+// so that a run that was interrupted resumes from the step that did not complete
+type Journal struct {
+	mutex     sync.Mutex
+	path      string
+	Workflows map[string]*Workflow `json:"workflows"`
+}
+
+// Workflow is a run of a command, it is resumed by the next run with the same inputs unless it finished
+type Workflow struct {
+	journal   *Journal
+	Inputs    map[string]string `json:"inputs,omitempty"`
+	Finished  bool              `json:"finished"`
+	StartedAt time.Time         `json:"startedAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Steps     map[string]*Step  `json:"steps"`
+}
+
+// Step holds the outputs of a step, outputs can be recorded before the step completes, e.g. change set ids
+type Step struct {
+	Completed   bool              `json:"completed"`
+	CompletedAt *time.Time        `json:"completedAt,omitempty"`
+	Outputs     map[string]string `json:"outputs,omitempty"`
+}
+
+// GetFolderPath returns the folder of the journals, ~/.devenv/state
+func GetFolderPath() string {
+	return filepath.Join(fixed.GetConfigFolderPath(), "state")
+}
+
+// New returns an empty journal for the environment, it replaces the saved one once a workflow begins
+func New(environmentName string) *Journal {
+	return &Journal{
+		path:      filepath.Join(GetFolderPath(), fmt.Sprintf("%s.json", environmentName)),
+		Workflows: map[string]*Workflow{},
+	}
+}
+
+// Open reads the journal of the environment, an environment without a journal gets an empty one
+func Open(environmentName string) (*Journal, error) {
+	journal := New(environmentName)
+	content, err := ioutil.ReadFile(journal.path)
+	if os.IsNotExist(err) {
+		return journal, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, journal); err != nil {
+		return nil, fmt.Errorf("%s is not a valid journal, %w", journal.path, err)
+	}
+	if journal.Workflows == nil {
+		journal.Workflows = map[string]*Workflow{}
+	}
+	for _, workflow := range journal.Workflows {
+		workflow.journal = journal
+		if workflow.Steps == nil {
+			workflow.Steps = map[string]*Step{}
+		}
+	}
+	return journal, nil
+}
+
+// Path returns the file of the journal
+func (journal *Journal) Path() string {
+	return journal.path
+}
+
+// save writes the journal to a temporary file first so that an interruption never leaves half a journal, the caller holds the mutex
+func (journal *Journal) save() error {
+	content, err := json.MarshalIndent(journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(journal.path), 0700); err != nil {
+		return err
+	}
+	temporaryPath := journal.path + ".tmp"
+	if err := ioutil.WriteFile(temporaryPath, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, journal.path)
+}
+
+func sameInputs(recorded map[string]string, inputs map[string]string) bool {
+	if len(recorded) != len(inputs) {
+		return false
+	}
+	for key, value := range inputs {
+		if recordedValue, ok := recorded[key]; !ok || recordedValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Begin resumes the workflow when its previous run did not finish and had the same inputs, otherwise it starts over,
+// fresh always starts over
+func (journal *Journal) Begin(key string, inputs map[string]string, fresh bool) (workflow *Workflow, resumed bool, err error) {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	now := time.Now().UTC()
+	workflow, exists := journal.Workflows[key]
+	resumed = exists && !fresh && !workflow.Finished && sameInputs(workflow.Inputs, inputs)
+	if !resumed {
+		workflow = &Workflow{
+			journal:   journal,
+			Inputs:    inputs,
+			StartedAt: now,
+			Steps:     map[string]*Step{},
+		}
+		journal.Workflows[key] = workflow
+	}
+	workflow.UpdatedAt = now
+	return workflow, resumed, journal.save()
+}
+
+// Forget removes the workflows, e.g. stopping the environment makes the instance recorded by start obsolete
+func (journal *Journal) Forget(keys ...string) error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	forgotten := false
+	for _, key := range keys {
+		if _, exists := journal.Workflows[key]; exists {
+			delete(journal.Workflows, key)
+			forgotten = true
+		}
+	}
+	if !forgotten {
+		return nil
+	}
+	return journal.save()
+}
+
+// Remove deletes the journal, e.g. when the environment is torn down
+func (journal *Journal) Remove() error {
+	journal.mutex.Lock()
+	defer journal.mutex.Unlock()
+	journal.Workflows = map[string]*Workflow{}
+	err := os.Remove(journal.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// getStep returns the step, creating it when it has not recorded anything yet, the caller holds the mutex
+func (workflow *Workflow) getStep(name string) *Step {
+	step, exists := workflow.Steps[name]
+	if !exists {
+		step = &Step{}
+		workflow.Steps[name] = step
+	}
+	return step
+}
+
+// Completed returns the outputs of the step when a previous run completed it, a nil workflow has no completed steps
+func (workflow *Workflow) Completed(name string) (outputs map[string]string, completed bool) {
+	if workflow == nil {
+		return nil, false
+	}
+	workflow.journal.mutex.Lock()
+	defer workflow.journal.mutex.Unlock()
+	step, exists := workflow.Steps[name]
+	if !exists || !step.Completed {
+		return nil, false
+	}
+	return step.Outputs, true
+}
+
+// Output returns an output recorded by the step, whether or not it completed
+func (workflow *Workflow) Output(name string, key string) (string, bool) {
+	if workflow == nil {
+		return "", false
+	}
+	workflow.journal.mutex.Lock()
+	defer workflow.journal.mutex.Unlock()
+	step, exists := workflow.Steps[name]
+	if !exists {
+		return "", false
+	}
+	value, exists := step.Outputs[key]
+	return value, exists
+}
+
+// Record saves an output of a step that has not completed yet
+func (workflow *Workflow) Record(name string, key string, value string) error {
+	if workflow == nil {
+		return nil
+	}
+	workflow.journal.mutex.Lock()
+	defer workflow.journal.mutex.Unlock()
+	step := workflow.getStep(name)
+	if step.Outputs == nil {
+		step.Outputs = map[string]string{}
+	}
+	step.Outputs[key] = value
+	workflow.UpdatedAt = time.Now().UTC()
+	return workflow.journal.save()
+}
+
+// Complete marks the step as completed with its outputs, they are added to the outputs recorded while it ran
+func (workflow *Workflow) Complete(name string, outputs map[string]string) error {
+	if workflow == nil {
+		return nil
+	}
+	workflow.journal.mutex.Lock()
+	defer workflow.journal.mutex.Unlock()
+	step := workflow.getStep(name)
+	if step.Outputs == nil && len(outputs) > 0 {
+		step.Outputs = map[string]string{}
+	}
+	for key, value := range outputs {
+		step.Outputs[key] = value
+	}
+	now := time.Now().UTC()
+	step.Completed = true
+	step.CompletedAt = &now
+	workflow.UpdatedAt = now
+	return workflow.journal.save()
+}
+
+// Forget removes the steps so that they run again, e.g. the instances recorded by start were replaced
+func (workflow *Workflow) Forget(names ...string) error {
+	if workflow == nil {
+		return nil
+	}
+	workflow.journal.mutex.Lock()
+	defer workflow.journal.mutex.Unlock()
+	for _, name := range names {
+		delete(workflow.Steps, name)
+	}
+	workflow.UpdatedAt = time.Now().UTC()
+	return workflow.journal.save()
+}
+
+// Finish marks the workflow as finished, the next run starts over but the outputs are kept for reference
+func (workflow *Workflow) Finish() error {
+	if workflow == nil {
+		return nil
+	}
+	workflow.journal.mutex.Lock()
+	defer workflow.journal.mutex.Unlock()
+	workflow.Finished = true
+	workflow.UpdatedAt = time.Now().UTC()
+	return workflow.journal.save()
+}
@@ -0,0 +1,266 @@
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	whale "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-connections/nat"
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/provider/docker"
+	"github.com/kahgeh/devenv/provider/manifest"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+const (
+	envoyImage           = "envoyproxy/envoy-alpine:v1.15-latest"
+	frontProxyDockerfile = `FROM ` + envoyImage + `
+COPY envoy.yaml /etc/envoy/envoy.yaml
+EXPOSE 80/tcp
+`
+)
+
+func generateID() string {
+	return fmt.Sprintf("%v", time.Now().UnixNano())
+}
+
+func (session *Session) getFrontProxyPath() string {
+	return fmt.Sprintf("%s/front-proxy", session.getEnvironmentFolderPath())
+}
+
+func (session *Session) writeFrontProxyFiles() (string, error) {
+	log := logger.New()
+	defer log.LogDone()
+
+	containers, err := session.listContainers()
+	if err != nil {
+		return "", provideTypes.NewDockerError("list containers", err)
+	}
+	routes := discoverRoutes(containers)
+
+	frontProxyPath := session.getFrontProxyPath()
+	err = os.MkdirAll(frontProxyPath, 0700)
+	if err != nil {
+		return "", provideTypes.NewError(fmt.Sprintf("ensure %q exist", frontProxyPath), err)
+	}
+
+	envoyConfigPath := fmt.Sprintf("%s/envoy.yaml", frontProxyPath)
+	envoyConfig, err := os.Create(envoyConfigPath)
+	if err != nil {
+		return "", provideTypes.NewError(fmt.Sprintf("create %s", envoyConfigPath), err)
+	}
+	defer utils.CloseReadCloser(envoyConfig, func(s string) { log.Debug(s) })
+	err = writeEnvoyConfig(envoyConfig, routes)
+	if err != nil {
+		return "", provideTypes.NewError(fmt.Sprintf("write %s", envoyConfigPath), err)
+	}
+	log.Debugf("%v routes written to %s", len(routes), envoyConfigPath)
+
+	dockerfilePath := fmt.Sprintf("%s/Dockerfile", frontProxyPath)
+	err = ioutil.WriteFile(dockerfilePath, []byte(frontProxyDockerfile), 0600)
+	if err != nil {
+		return "", provideTypes.NewError(fmt.Sprintf("write %s", dockerfilePath), err)
+	}
+	return frontProxyPath, nil
+}
+
+// applyManifest sets the environment variables, labels, health check and reservations of the app container
+func applyManifest(appName string, appManifest *manifest.Manifest, config *container.Config, hostConfig *container.HostConfig) {
+	for name, value := range appManifest.Environment {
+		config.Env = append(config.Env, fmt.Sprintf("%s=%s", name, value))
+	}
+	for key, value := range appManifest.Labels(appName) {
+		config.Labels[key] = value
+	}
+	if healthCheck := appManifest.HealthCheck; healthCheck != nil {
+		config.Healthcheck = &container.HealthConfig{
+			Test:        []string{"CMD-SHELL", healthCheck.Command},
+			Interval:    healthCheck.Interval,
+			Timeout:     healthCheck.Timeout,
+			StartPeriod: healthCheck.StartPeriod,
+			Retries:     int(healthCheck.Retries),
+		}
+	}
+	hostConfig.CPUShares = appManifest.Cpu
+	hostConfig.MemoryReservation = appManifest.MemoryReservation * 1024 * 1024
+}
+
+// runContainer replaces the container of the app, the manifest is nil for the front proxy
+func (session *Session) runContainer(appName string, image string, portBindings nat.PortMap, appManifest *manifest.Manifest) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	containerName := session.getContainerName(appName)
+	err := session.client.ContainerRemove(ctx.GetContext(), containerName, types.ContainerRemoveOptions{Force: true})
+	if err != nil && !whale.IsErrNotFound(err) {
+		err = provideTypes.NewDockerError(fmt.Sprintf("remove previous %s container", appName), err)
+		log.Fail(err)
+		return err
+	}
+
+	exposedPorts := nat.PortSet{}
+	for port := range portBindings {
+		exposedPorts[port] = struct{}{}
+	}
+	config := &container.Config{
+		Image:        image,
+		ExposedPorts: exposedPorts,
+		Labels: map[string]string{
+			labelEnvironment: session.envName,
+			labelApp:         appName,
+		},
+	}
+	hostConfig := &container.HostConfig{
+		PortBindings:  portBindings,
+		RestartPolicy: container.RestartPolicy{Name: "unless-stopped"},
+	}
+	if appManifest != nil {
+		applyManifest(appName, appManifest, config, hostConfig)
+	}
+	networkName := session.getNetworkName()
+	log.Infof("creating %s container...", appName)
+	created, err := session.client.ContainerCreate(ctx.GetContext(),
+		config,
+		hostConfig,
+		&network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				networkName: {Aliases: []string{appName}},
+			},
+		},
+		containerName)
+	if err != nil {
+		err = provideTypes.NewDockerError(fmt.Sprintf("create %s container", appName), err)
+		log.Fail(err)
+		return err
+	}
+
+	log.Infof("starting %s container...", appName)
+	err = session.client.ContainerStart(ctx.GetContext(), created.ID, types.ContainerStartOptions{})
+	if err != nil {
+		err = provideTypes.NewDockerError(fmt.Sprintf("start %s container", appName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Result("image", image)
+	log.Result("container", containerName)
+	log.Succeedf("%s is running as %s", appName, containerName)
+	return nil
+}
+
+func (session *Session) deployFrontProxy(id string) error {
+	appName := string(cmdTypes.KnownAppFrontProxy)
+	frontProxyPath, err := session.writeFrontProxyFiles()
+	if err != nil {
+		return err
+	}
+	tag, err := docker.BuildImage(frontProxyPath, session.getContainerName(appName), id, provideTypes.BuildOptions{})
+	if err != nil {
+		return err
+	}
+	return session.runContainer(appName, *tag, nat.PortMap{
+		"80/tcp": []nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "80"}},
+	}, nil)
+}
+
+// refreshFrontProxy redeploys the front proxy, if it has been deployed, so it picks up the routes of new apps
+func (session *Session) refreshFrontProxy(id string) error {
+	containerName := session.getContainerName(string(cmdTypes.KnownAppFrontProxy))
+	_, err := session.client.ContainerInspect(ctx.GetContext(), containerName)
+	if err != nil {
+		return nil
+	}
+	return session.deployFrontProxy(id)
+}
+
+// findExistingImage returns the image given with --image, pulled when the daemon does not have it,
+// or the image built by an earlier deploy with the tag given with --tag
+func (session *Session) findExistingImage(appName string, image string, tag string) (string, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	if tag != "" {
+		image = fmt.Sprintf("%s:%s", session.getContainerName(appName), tag)
+	}
+	_, _, err := session.client.ImageInspectWithRaw(ctx.GetContext(), image)
+	if err == nil {
+		log.Succeedf("Found %s", image)
+		return image, nil
+	}
+	if !whale.IsErrNotFound(err) {
+		err = provideTypes.NewDockerError(fmt.Sprintf("inspect image %s", image), err)
+		log.Fail(err)
+		return "", err
+	}
+	if tag != "" {
+		err = provideTypes.NewUserError(fmt.Sprintf("find image %s", image), fmt.Errorf("%s was not built by an earlier deploy", image))
+		log.Fail(err)
+		return "", err
+	}
+
+	log.Infof("pulling %s...", image)
+	response, err := session.client.ImagePull(ctx.GetContext(), image, types.ImagePullOptions{})
+	if err != nil {
+		err = provideTypes.NewDockerError(fmt.Sprintf("pull image %s", image), err)
+		log.Fail(err)
+		return "", err
+	}
+	defer utils.CloseReadCloser(response, func(s string) { log.Debug(s) })
+	if err := jsonmessage.DisplayJSONMessagesStream(response, ioutil.Discard, 0, false, nil); err != nil {
+		err = provideTypes.NewDockerError(fmt.Sprintf("pull image %s", image), err)
+		log.Fail(err)
+		return "", err
+	}
+	log.Succeedf("Pulled %s", image)
+	return image, nil
+}
+
+// Deploy builds the image, unless an existing one is given, and (re)creates its container on the environment network
+func (session *Session) Deploy(parameters *provideTypes.DeployParameters) error {
+	appType := parameters.AppType
+	appName := parameters.AppName
+	path := parameters.Path
+	existingImage := parameters.Image != "" || parameters.Tag != ""
+
+	if appType == provideTypes.FrontProxy && existingImage {
+		return provideTypes.NewUserError("deploy front proxy",
+			fmt.Errorf("the local front proxy is built from the routes of the apps, --image and --tag are not supported"))
+	}
+	if err := session.createNetwork(); err != nil {
+		return err
+	}
+	id := generateID()
+	if appType == provideTypes.FrontProxy {
+		return session.deployFrontProxy(id)
+	}
+
+	var tag *string
+	if existingImage {
+		image, err := session.findExistingImage(appName, parameters.Image, parameters.Tag)
+		if err != nil {
+			return err
+		}
+		tag = &image
+	} else {
+		built, err := docker.BuildImage(path, session.getContainerName(appName), id, parameters.Build)
+		if err != nil {
+			return err
+		}
+		tag = built
+	}
+	appManifest := parameters.Manifest
+	if appManifest == nil {
+		appManifest = manifest.Default()
+	}
+	if err := session.runContainer(appName, *tag, nat.PortMap{}, appManifest); err != nil {
+		return err
+	}
+	return session.refreshFrontProxy(id)
+}
@@ -0,0 +1,53 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+func (session *Session) createNetwork() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	networkName := session.getNetworkName()
+	networks, err := session.client.NetworkList(ctx.GetContext(), types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", networkName)),
+	})
+	if err != nil {
+		err = provideTypes.NewDockerError("access docker", err)
+		log.Fail(err)
+		return err
+	}
+	for _, network := range networks {
+		if network.Name == networkName {
+			log.Succeedf("Network %q already exists", networkName)
+			return nil
+		}
+	}
+
+	_, err = session.client.NetworkCreate(ctx.GetContext(), networkName, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         "bridge",
+		Labels:         map[string]string{labelEnvironment: session.envName},
+	})
+	if err != nil {
+		err = provideTypes.NewDockerError(fmt.Sprintf("create network %q", networkName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeedf("Created network %q", networkName)
+	return nil
+}
+
+// Initialise creates the bridge network shared by the front proxy and apps
+func (session *Session) Initialise(_ *provideTypes.InitialisationParameters) error {
+	log := logger.New()
+	defer log.LogDone()
+	return session.createNetwork()
+}
@@ -0,0 +1,43 @@
+package local
+
+import (
+	"fmt"
+	"strings"
+
+	whale "github.com/docker/docker/client"
+	"github.com/kahgeh/devenv/fixed"
+	"github.com/kahgeh/devenv/provider/types"
+)
+
+const (
+	labelEnvironment = "devenv.env"
+	labelApp         = "devenv.app"
+)
+
+type Session struct {
+	client  *whale.Client
+	envName string
+}
+
+func CreateLocalSession(options *types.SessionOptions) (*Session, error) {
+	if options.Plan {
+		return nil, types.NewUserError("create local session", fmt.Errorf("plan is not supported by the local provider"))
+	}
+	client, err := whale.NewClientWithOpts(whale.FromEnv, whale.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, types.NewDockerError("access docker", err)
+	}
+	return &Session{client: client, envName: options.EnvironmentName}, nil
+}
+
+func (session *Session) getNetworkName() string {
+	return fmt.Sprintf("devenv-%s", strings.ToLower(session.envName))
+}
+
+func (session *Session) getContainerName(appName string) string {
+	return fmt.Sprintf("%s-%s", strings.ToLower(session.envName), appName)
+}
+
+func (session *Session) getEnvironmentFolderPath() string {
+	return fmt.Sprintf("%s/local/%s", fixed.GetConfigFolderPath(), strings.ToLower(session.envName))
+}
@@ -0,0 +1,126 @@
+package local
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/docker/docker/api/types"
+)
+
+// route is an envoy route and cluster derived from the CLUSTER_<port>_* labels of a container,
+// the same labels whale-disco uses to discover services on ecs instances
+type route struct {
+	Prefix  string
+	Cluster string
+	Host    string
+	Port    int
+}
+
+var clusterLabelPattern = regexp.MustCompile(`^CLUSTER_(\d+)_(NAME|URLPREFIX|CATEGORY)$`)
+
+func getRoutes(containerName string, labels map[string]string) []route {
+	routesByPort := map[int]*route{}
+	for key, value := range labels {
+		matches := clusterLabelPattern.FindStringSubmatch(key)
+		if matches == nil {
+			continue
+		}
+		port, err := strconv.Atoi(matches[1])
+		if err != nil {
+			continue
+		}
+		r, exists := routesByPort[port]
+		if !exists {
+			r = &route{Host: containerName, Port: port}
+			routesByPort[port] = r
+		}
+		switch matches[2] {
+		case "NAME":
+			r.Cluster = value
+		case "URLPREFIX":
+			r.Prefix = fmt.Sprintf("/%s", strings.TrimPrefix(value, "/"))
+		}
+	}
+
+	var routes []route
+	for _, r := range routesByPort {
+		if r.Cluster == "" {
+			r.Cluster = fmt.Sprintf("%s-%v", containerName, r.Port)
+		}
+		if r.Prefix == "" {
+			r.Prefix = fmt.Sprintf("/%s", r.Cluster)
+		}
+		routes = append(routes, *r)
+	}
+	return routes
+}
+
+func discoverRoutes(containers []types.Container) []route {
+	var routes []route
+	for _, container := range containers {
+		if len(container.Names) == 0 {
+			continue
+		}
+		containerName := strings.TrimPrefix(container.Names[0], "/")
+		routes = append(routes, getRoutes(containerName, container.Labels)...)
+	}
+	// longest prefixes first, envoy picks the first matching route
+	sort.Slice(routes, func(i, j int) bool {
+		return len(routes[i].Prefix) > len(routes[j].Prefix)
+	})
+	return routes
+}
+
+const envoyConfigTemplate = `admin:
+  access_log_path: /dev/null
+  address:
+    socket_address: { address: 0.0.0.0, port_value: 9901 }
+static_resources:
+  listeners:
+  - name: listener_http
+    address:
+      socket_address: { address: 0.0.0.0, port_value: 80 }
+    filter_chains:
+    - filters:
+      - name: envoy.filters.network.http_connection_manager
+        typed_config:
+          "@type": type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager
+          codec_type: AUTO
+          stat_prefix: ingress_http
+          route_config:
+            name: local_route
+            virtual_hosts:
+            - name: backend
+              domains: ["*"]
+              routes:{{if not .}} []{{end}}
+{{- range .}}
+              - match: { prefix: "{{.Prefix}}" }
+                route: { cluster: "{{.Cluster}}" }
+{{- end}}
+          http_filters:
+          - name: envoy.filters.http.router
+  clusters:{{if not .}} []{{end}}
+{{- range .}}
+  - name: "{{.Cluster}}"
+    connect_timeout: 1s
+    type: STRICT_DNS
+    dns_lookup_family: V4_ONLY
+    load_assignment:
+      cluster_name: "{{.Cluster}}"
+      endpoints:
+      - lb_endpoints:
+        - endpoint:
+            address:
+              socket_address: { address: {{.Host}}, port_value: {{.Port}} }
+{{- end}}
+`
+
+func writeEnvoyConfig(writer io.Writer, routes []route) error {
+	t := template.Must(template.New("envoy").Parse(envoyConfigTemplate))
+	return t.Execute(writer, routes)
+}
@@ -0,0 +1,57 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+
+	"github.com/kahgeh/devenv/logger"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+func (session *Session) listContainers() ([]types.Container, error) {
+	return session.client.ContainerList(ctx.GetContext(), types.ContainerListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", labelEnvironment, session.envName))),
+	})
+}
+
+func (session *Session) startContainers() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	containers, err := session.listContainers()
+	if err != nil {
+		err = provideTypes.NewDockerError("list containers", err)
+		log.Fail(err)
+		return err
+	}
+	for _, container := range containers {
+		if container.State == "running" {
+			continue
+		}
+		log.Infof("starting %s...", container.Labels[labelApp])
+		err = session.client.ContainerStart(ctx.GetContext(), container.ID, types.ContainerStartOptions{})
+		if err != nil {
+			err = provideTypes.NewDockerError(fmt.Sprintf("start %s", container.Labels[labelApp]), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeed()
+	return nil
+}
+
+// Start ensures the network exists and starts every app previously deployed to the environment
+func (session *Session) Start(parameters *provideTypes.StartParameters) error {
+	if parameters.StopAt != nil {
+		return provideTypes.NewUserError("schedule the shutdown",
+			fmt.Errorf("the local provider does not support --ttl and --stop-at"))
+	}
+	if err := session.createNetwork(); err != nil {
+		return err
+	}
+	return session.startContainers()
+}
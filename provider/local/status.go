@@ -0,0 +1,62 @@
+package local
+
+import (
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/kahgeh/devenv/logger"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+const notFoundStatus = "NOT_FOUND"
+
+func (session *Session) collectStatus(parameters *provideTypes.StatusParameters) (*provideTypes.EnvironmentStatus, error) {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+	status := &provideTypes.EnvironmentStatus{EnvironmentName: parameters.EnvironmentName}
+
+	log.Info("getting network details...")
+	networkName := session.getNetworkName()
+	networks, err := session.client.NetworkList(ctx.GetContext(), types.NetworkListOptions{
+		Filters: filters.NewArgs(filters.Arg("name", networkName)),
+	})
+	if err != nil {
+		err = provideTypes.NewDockerError("access docker", err)
+		log.Fail(err)
+		return nil, err
+	}
+	networkStatus := provideTypes.StackStatus{Role: "network", Name: networkName, Status: notFoundStatus}
+	for _, network := range networks {
+		if network.Name == networkName {
+			networkStatus.Status = "AVAILABLE"
+		}
+	}
+	status.Stacks = append(status.Stacks, networkStatus)
+
+	log.Info("getting app details...")
+	containers, err := session.listContainers()
+	if err != nil {
+		err = provideTypes.NewDockerError("list containers", err)
+		log.Fail(err)
+		return nil, err
+	}
+	for _, container := range containers {
+		app := provideTypes.AppStatus{
+			Name:         container.Labels[labelApp],
+			StackName:    session.getContainerName(container.Labels[labelApp]),
+			StackStatus:  container.State,
+			DesiredCount: 1,
+		}
+		if container.State == "running" {
+			app.RunningCount = 1
+		}
+		status.Apps = append(status.Apps, app)
+	}
+	log.Succeed()
+	return status, nil
+}
+
+// Status reports the network and the app containers of the environment
+func (session *Session) Status(parameters *provideTypes.StatusParameters) (*provideTypes.EnvironmentStatus, error) {
+	return session.collectStatus(parameters)
+}
@@ -0,0 +1,40 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/kahgeh/devenv/logger"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+func (session *Session) stopContainers() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	containers, err := session.listContainers()
+	if err != nil {
+		err = provideTypes.NewDockerError("list containers", err)
+		log.Fail(err)
+		return err
+	}
+	for _, container := range containers {
+		if container.State != "running" {
+			continue
+		}
+		log.Infof("stopping %s...", container.Labels[labelApp])
+		err = session.client.ContainerStop(ctx.GetContext(), container.ID, nil)
+		if err != nil {
+			err = provideTypes.NewDockerError(fmt.Sprintf("stop %s", container.Labels[labelApp]), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeed()
+	return nil
+}
+
+// Stop stops every container of the environment, they are kept so start can bring them back
+func (session *Session) Stop() error {
+	return session.stopContainers()
+}
@@ -0,0 +1,63 @@
+package local
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/docker/api/types"
+	"github.com/kahgeh/devenv/logger"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+func (session *Session) removeContainers() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	containers, err := session.listContainers()
+	if err != nil {
+		err = provideTypes.NewDockerError("list containers", err)
+		log.Fail(err)
+		return err
+	}
+	for _, container := range containers {
+		log.Infof("removing %s...", container.Labels[labelApp])
+		err = session.client.ContainerRemove(ctx.GetContext(), container.ID, types.ContainerRemoveOptions{Force: true})
+		if err != nil {
+			err = provideTypes.NewDockerError(fmt.Sprintf("remove %s", container.Labels[labelApp]), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeed()
+	return nil
+}
+
+func (session *Session) deleteNetwork() error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	networkName := session.getNetworkName()
+	err := session.client.NetworkRemove(ctx.GetContext(), networkName)
+	if err != nil {
+		log.Debug(err.Error())
+		log.Infof("Network %q does not exists", networkName)
+	}
+
+	folderPath := session.getEnvironmentFolderPath()
+	if err := os.RemoveAll(folderPath); err != nil {
+		err = provideTypes.NewError(fmt.Sprintf("delete %s", folderPath), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+// Delete removes every container of the environment and its network
+func (session *Session) Delete() error {
+	if err := session.removeContainers(); err != nil {
+		return err
+	}
+	return session.deleteNetwork()
+}
@@ -0,0 +1,75 @@
+package local
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	whale "github.com/docker/docker/client"
+	cmdTypes "github.com/kahgeh/devenv/cmd/types"
+	"github.com/kahgeh/devenv/logger"
+	provideTypes "github.com/kahgeh/devenv/provider/types"
+	"github.com/kahgeh/devenv/utils/ctx"
+)
+
+func (session *Session) removeContainer(appName string) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	containerName := session.getContainerName(appName)
+	err := session.client.ContainerRemove(ctx.GetContext(), containerName, types.ContainerRemoveOptions{Force: true})
+	if err != nil {
+		if whale.IsErrNotFound(err) {
+			log.Succeedf("%s is not deployed", appName)
+			return nil
+		}
+		err = provideTypes.NewDockerError(fmt.Sprintf("remove %s container", appName), err)
+		log.Fail(err)
+		return err
+	}
+	log.Succeed()
+	return nil
+}
+
+// removeImages removes every image built for the app, they are tagged <container name>:<id>
+func (session *Session) removeImages(appName string) error {
+	log := logger.NewTaskLogger()
+	defer log.LogDone()
+
+	repository := session.getContainerName(appName)
+	images, err := session.client.ImageList(ctx.GetContext(), types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("reference", repository)),
+	})
+	if err != nil {
+		err = provideTypes.NewDockerError(fmt.Sprintf("list %s images", appName), err)
+		log.Fail(err)
+		return err
+	}
+	for _, image := range images {
+		_, err := session.client.ImageRemove(ctx.GetContext(), image.ID, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+		if err != nil && !whale.IsErrNotFound(err) {
+			err = provideTypes.NewDockerError(fmt.Sprintf("remove image %s", image.ID), err)
+			log.Fail(err)
+			return err
+		}
+	}
+	log.Succeedf("Removed %v %s images", len(images), appName)
+	return nil
+}
+
+// Undeploy removes the app container and, when purging images, the images built for it
+func (session *Session) Undeploy(parameters *provideTypes.UndeployParameters) error {
+	appName := parameters.AppName
+	if err := session.removeContainer(appName); err != nil {
+		return err
+	}
+	if parameters.PurgeImages {
+		if err := session.removeImages(appName); err != nil {
+			return err
+		}
+	}
+	if appName != string(cmdTypes.KnownAppFrontProxy) {
+		return session.refreshFrontProxy(generateID())
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FileName is the manifest kept next to the Dockerfile of an app
+const FileName = "devenv.yaml"
+
+// HealthCheck is the command docker runs in the container to find out whether the app is healthy
+type HealthCheck struct {
+	// Command runs with the container shell, the app is unhealthy when it exits with a non zero status
+	Command     string        `yaml:"command" json:"command"`
+	Interval    time.Duration `yaml:"interval" json:"interval"`
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	Retries     int64         `yaml:"retries" json:"retries"`
+	StartPeriod time.Duration `yaml:"startPeriod" json:"startPeriod"`
+}
+
+// Manifest is how an app is deployed, fields left out of devenv.yaml keep their defaults
+type Manifest struct {
+	ContainerPort int64 `yaml:"containerPort" json:"containerPort"`
+	// Cpu is reserved in cpu units, 1024 is a whole vCPU
+	Cpu int64 `yaml:"cpu" json:"cpu"`
+	// MemoryReservation is the soft memory limit in MiB
+	MemoryReservation int64 `yaml:"memoryReservation" json:"memoryReservation"`
+	DesiredCount      int64 `yaml:"desiredCount" json:"desiredCount"`
+	// RoutePrefix is the path the front proxy routes to the app, the Dockerfile labels are used when it is empty
+	RoutePrefix string            `yaml:"routePrefix" json:"routePrefix,omitempty"`
+	Environment map[string]string `yaml:"environment" json:"environment,omitempty"`
+	HealthCheck *HealthCheck      `yaml:"healthCheck" json:"healthCheck,omitempty"`
+}
+
+var (
+	environmentNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	routePrefixPattern     = regexp.MustCompile(`^/?[A-Za-z0-9._~-]+(/[A-Za-z0-9._~-]+)*/?$`)
+)
+
+// IsEnvironmentName reports whether the name can be used for an environment variable or a secret of an app
+func IsEnvironmentName(name string) bool {
+	return environmentNamePattern.MatchString(name)
+}
+
+// Default returns the settings apps are deployed with when they have no manifest
+func Default() *Manifest {
+	return &Manifest{
+		ContainerPort:     80,
+		Cpu:               128,
+		MemoryReservation: 512,
+		DesiredCount:      1,
+	}
+}
+
+func defaultHealthCheck() HealthCheck {
+	return HealthCheck{
+		Interval: 30 * time.Second,
+		Timeout:  5 * time.Second,
+		Retries:  3,
+	}
+}
+
+// UnmarshalYAML applies the defaults to the fields left out of the health check
+func (healthCheck *HealthCheck) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain HealthCheck
+	values := plain(defaultHealthCheck())
+	if err := unmarshal(&values); err != nil {
+		return err
+	}
+	*healthCheck = HealthCheck(values)
+	return nil
+}
+
+// Parse reads the manifest, fields that are not part of it are rejected
+func Parse(content []byte) (*Manifest, error) {
+	manifest := Default()
+	if err := yaml.UnmarshalStrict(content, manifest); err != nil {
+		return nil, err
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Read returns the manifest of the app in the folder, or the defaults when the folder does not have one
+func Read(folderPath string) (*Manifest, error) {
+	filePath := filepath.Join(folderPath, FileName)
+	content, err := ioutil.ReadFile(filePath)
+	if os.IsNotExist(err) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("%s is not valid, %w", filePath, err)
+	}
+	return manifest, nil
+}
+
+func isWholeSeconds(duration time.Duration) bool {
+	return duration%time.Second == 0
+}
+
+func checkSeconds(name string, duration time.Duration, min time.Duration, max time.Duration) string {
+	if !isWholeSeconds(duration) || duration < min || duration > max {
+		return fmt.Sprintf("%s must be a whole number of seconds between %v and %v", name, min, max)
+	}
+	return ""
+}
+
+// Validate checks the manifest against what ecs and the front proxy accept
+func (manifest *Manifest) Validate() error {
+	var problems []string
+	if manifest.ContainerPort < 1 || manifest.ContainerPort > 65535 {
+		problems = append(problems, "containerPort must be between 1 and 65535")
+	}
+	if manifest.Cpu < 0 || manifest.Cpu > 10240 {
+		problems = append(problems, "cpu must be between 0 and 10240 cpu units")
+	}
+	if manifest.MemoryReservation < 6 {
+		problems = append(problems, "memoryReservation must be at least 6 MiB")
+	}
+	if manifest.DesiredCount < 0 {
+		problems = append(problems, "desiredCount cannot be negative")
+	}
+	if manifest.RoutePrefix != "" && !routePrefixPattern.MatchString(manifest.RoutePrefix) {
+		problems = append(problems, fmt.Sprintf("routePrefix %q must be a url path, e.g. /hello", manifest.RoutePrefix))
+	}
+	for name := range manifest.Environment {
+		if !IsEnvironmentName(name) {
+			problems = append(problems, fmt.Sprintf("environment variable %q must only contain letters, digits and underscores", name))
+		}
+	}
+	if healthCheck := manifest.HealthCheck; healthCheck != nil {
+		if strings.TrimSpace(healthCheck.Command) == "" {
+			problems = append(problems, "healthCheck.command is required")
+		}
+		for _, problem := range []string{
+			checkSeconds("healthCheck.interval", healthCheck.Interval, 5*time.Second, 300*time.Second),
+			checkSeconds("healthCheck.timeout", healthCheck.Timeout, 2*time.Second, 60*time.Second),
+			checkSeconds("healthCheck.startPeriod", healthCheck.StartPeriod, 0, 300*time.Second),
+		} {
+			if problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+		if healthCheck.Retries < 1 || healthCheck.Retries > 10 {
+			problems = append(problems, "healthCheck.retries must be between 1 and 10")
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// Labels returns the CLUSTER_<port>_* labels the front proxy routes to the app with, none when there is no route prefix
+func (manifest *Manifest) Labels(appName string) map[string]string {
+	if manifest.RoutePrefix == "" {
+		return nil
+	}
+	return map[string]string{
+		fmt.Sprintf("CLUSTER_%d_NAME", manifest.ContainerPort):      appName,
+		fmt.Sprintf("CLUSTER_%d_URLPREFIX", manifest.ContainerPort): strings.Trim(manifest.RoutePrefix, "/"),
+	}
+}
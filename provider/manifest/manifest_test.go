@@ -0,0 +1,172 @@
+package manifest
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    func(manifest *Manifest) bool
+		wantErr string
+	}{
+		{
+			name:    "empty manifest keeps the defaults",
+			content: "",
+			want: func(manifest *Manifest) bool {
+				return reflect.DeepEqual(manifest, Default())
+			},
+		},
+		{
+			name:    "values on the bounds are accepted",
+			content: "containerPort: 65535\ncpu: 10240\nmemoryReservation: 6\ndesiredCount: 0\n",
+			want: func(manifest *Manifest) bool {
+				return manifest.ContainerPort == 65535 && manifest.Cpu == 10240 &&
+					manifest.MemoryReservation == 6 && manifest.DesiredCount == 0
+			},
+		},
+		{
+			name:    "health check fields left out keep their defaults",
+			content: "healthCheck:\n  command: curl -f http://localhost/health\n  interval: 10s\n",
+			want: func(manifest *Manifest) bool {
+				healthCheck := manifest.HealthCheck
+				return healthCheck != nil && healthCheck.Interval == 10*time.Second &&
+					healthCheck.Timeout == 5*time.Second && healthCheck.Retries == 3 && healthCheck.StartPeriod == 0
+			},
+		},
+		{
+			name:    "unknown field",
+			content: "port: 8080\n",
+			wantErr: "field port not found",
+		},
+		{
+			name:    "unknown health check field",
+			content: "healthCheck:\n  command: true\n  grace: 10s\n",
+			wantErr: "field grace not found",
+		},
+		{
+			name:    "port out of range",
+			content: "containerPort: 65536\n",
+			wantErr: "containerPort must be between 1 and 65535",
+		},
+		{
+			name:    "port zero",
+			content: "containerPort: 0\n",
+			wantErr: "containerPort must be between 1 and 65535",
+		},
+		{
+			name:    "cpu above the largest instance",
+			content: "cpu: 10241\n",
+			wantErr: "cpu must be between 0 and 10240 cpu units",
+		},
+		{
+			name:    "memory below the docker minimum",
+			content: "memoryReservation: 5\n",
+			wantErr: "memoryReservation must be at least 6 MiB",
+		},
+		{
+			name:    "negative desired count",
+			content: "desiredCount: -1\n",
+			wantErr: "desiredCount cannot be negative",
+		},
+		{
+			name:    "route prefix with a query",
+			content: "routePrefix: /hello?x=1\n",
+			wantErr: `routePrefix "/hello?x=1" must be a url path`,
+		},
+		{
+			name:    "environment variable name with a dash",
+			content: "environment:\n  LOG-LEVEL: debug\n",
+			wantErr: `environment variable "LOG-LEVEL" must only contain letters, digits and underscores`,
+		},
+		{
+			name:    "health check without a command",
+			content: "healthCheck:\n  interval: 10s\n",
+			wantErr: "healthCheck.command is required",
+		},
+		{
+			name:    "health check interval below the ecs minimum",
+			content: "healthCheck:\n  command: true\n  interval: 4s\n",
+			wantErr: "healthCheck.interval must be a whole number of seconds between 5s and 5m0s",
+		},
+		{
+			name:    "health check timeout with a fraction of a second",
+			content: "healthCheck:\n  command: true\n  timeout: 2500ms\n",
+			wantErr: "healthCheck.timeout must be a whole number of seconds between 2s and 1m0s",
+		},
+		{
+			name:    "health check start period above the ecs maximum",
+			content: "healthCheck:\n  command: true\n  startPeriod: 301s\n",
+			wantErr: "healthCheck.startPeriod must be a whole number of seconds between 0s and 5m0s",
+		},
+		{
+			name:    "health check retries above the ecs maximum",
+			content: "healthCheck:\n  command: true\n  retries: 11\n",
+			wantErr: "healthCheck.retries must be between 1 and 10",
+		},
+		{
+			name:    "every problem is reported",
+			content: "containerPort: 0\ndesiredCount: -1\n",
+			wantErr: "containerPort must be between 1 and 65535, desiredCount cannot be negative",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest, err := Parse([]byte(test.content))
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Parse() error = %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v, want no error", err)
+			}
+			if !test.want(manifest) {
+				t.Errorf("Parse() = %+v", manifest)
+			}
+		})
+	}
+}
+
+func TestLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		routePrefix string
+		want        map[string]string
+	}{
+		{
+			name:        "no route prefix",
+			routePrefix: "",
+			want:        nil,
+		},
+		{
+			name:        "slashes are trimmed",
+			routePrefix: "/hello/",
+			want: map[string]string{
+				"CLUSTER_8080_NAME":      "hello",
+				"CLUSTER_8080_URLPREFIX": "hello",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manifest := Default()
+			manifest.ContainerPort = 8080
+			manifest.RoutePrefix = test.routePrefix
+			labels := manifest.Labels("hello")
+			if len(labels) != len(test.want) {
+				t.Fatalf("Labels() = %v, want %v", labels, test.want)
+			}
+			for key, value := range test.want {
+				if labels[key] != value {
+					t.Errorf("Labels()[%q] = %q, want %q", key, labels[key], value)
+				}
+			}
+		})
+	}
+}
@@ -5,21 +5,60 @@ import (
 	"github.com/kahgeh/devenv/provider/types"
 
 	daws "github.com/kahgeh/devenv/provider/aws"
+	"github.com/kahgeh/devenv/provider/local"
 )
 
 type Provider string
 
 const (
-	Aws Provider = "aws"
+	Aws   Provider = "aws"
+	Local Provider = "local"
 )
 
 // Session is the cloud provider session
 type Session interface {
-	Initialise(parameters *types.InitialisationParameters)
-	Delete()
-	Start(config *types.StartParameters)
-	Stop()
-	Deploy(parameters *types.DeployParameters)
+	Initialise(parameters *types.InitialisationParameters) error
+	Delete() error
+	Start(config *types.StartParameters) error
+	Stop() error
+	Deploy(parameters *types.DeployParameters) error
+	Undeploy(parameters *types.UndeployParameters) error
+	Status(parameters *types.StatusParameters) (*types.EnvironmentStatus, error)
+}
+
+// LogReader is implemented by sessions that can show the logs of apps and instances
+type LogReader interface {
+	Logs(parameters *types.LogsParameters) error
+}
+
+// SecureShell is implemented by sessions whose environments run on instances that can be reached with ssh
+type SecureShell interface {
+	Ssh(parameters *types.SshParameters) error
+}
+
+// ShutdownScheduler is implemented by sessions whose environments can shut themselves down at a deadline
+type ShutdownScheduler interface {
+	GetShutdownSchedule() (*types.ShutdownSchedule, error)
+	ExtendShutdown(parameters *types.ExtendShutdownParameters) (*types.ShutdownSchedule, error)
+}
+
+// SecretStore is implemented by sessions that can keep the secrets of apps and pass them to the app containers
+type SecretStore interface {
+	SetSecret(parameters *types.SecretParameters) error
+	GetSecret(parameters *types.SecretParameters) (string, error)
+	ListSecrets(parameters *types.SecretParameters) ([]string, error)
+	RemoveSecret(parameters *types.SecretParameters) error
+}
+
+// ReleaseHistory is implemented by sessions that record the deploys of apps and can deploy an earlier one again
+type ReleaseHistory interface {
+	Releases(parameters *types.ReleasesParameters) ([]types.Release, error)
+	Rollback(parameters *types.RollbackParameters) (*types.Release, error)
+}
+
+// Watcher is implemented by sessions whose environment instances can be replaced, e.g. after a spot interruption
+type Watcher interface {
+	Watch(parameters *types.WatchParameters) error
 }
 
 // NotSupported error
@@ -32,10 +71,17 @@ func (e *NotSupported) Error() string {
 	return e.s
 }
 
-func NewSession(provider Provider) (Session, error) {
+// Kind classifies an unsupported provider as a mistake of the user
+func (e *NotSupported) Kind() types.ErrorKind {
+	return types.UserErrorKind
+}
+
+func NewSession(provider Provider, options *types.SessionOptions) (Session, error) {
 	switch provider {
 	case Aws:
-		return daws.CreateAwsSession()
+		return daws.CreateAwsSession(options)
+	case Local:
+		return local.CreateLocalSession(options)
 	default:
 		return nil, &NotSupported{s: fmt.Sprintf("Provider %v not supported", provider), name: string(provider)}
 	}
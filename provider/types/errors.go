@@ -0,0 +1,75 @@
+package types
+
+import "fmt"
+
+// ErrorKind tells apart who can act on an error, the cli exits with a different code for each
+type ErrorKind int
+
+const (
+	UnknownErrorKind ErrorKind = iota
+	// UserErrorKind is for errors the user can fix, e.g. a missing argument or an environment that is not started
+	UserErrorKind
+	AwsErrorKind
+	DockerErrorKind
+)
+
+// ClassifiedError is implemented by errors that know their kind
+type ClassifiedError interface {
+	error
+	Kind() ErrorKind
+}
+
+// GetErrorKind returns the kind of the innermost classified error of the chain
+func GetErrorKind(err error) ErrorKind {
+	kind := UnknownErrorKind
+	for err != nil {
+		if classified, ok := err.(ClassifiedError); ok && classified.Kind() != UnknownErrorKind {
+			kind = classified.Kind()
+		}
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = wrapper.Unwrap()
+	}
+	return kind
+}
+
+// OperationError wraps the error of an operation, e.g. "create vpc", with its kind
+type OperationError struct {
+	kind      ErrorKind
+	Operation string
+	Err       error
+}
+
+func (e *OperationError) Error() string {
+	return fmt.Sprintf("fail to %s, %v", e.Operation, e.Err)
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.Err
+}
+
+func (e *OperationError) Kind() ErrorKind {
+	return e.kind
+}
+
+// NewUserError reports an operation that failed because of the input or the state of the environment
+func NewUserError(operation string, err error) error {
+	return &OperationError{kind: UserErrorKind, Operation: operation, Err: err}
+}
+
+// NewAwsError reports an operation that failed calling aws
+func NewAwsError(operation string, err error) error {
+	return &OperationError{kind: AwsErrorKind, Operation: operation, Err: err}
+}
+
+// NewDockerError reports an operation that failed calling docker
+func NewDockerError(operation string, err error) error {
+	return &OperationError{kind: DockerErrorKind, Operation: operation, Err: err}
+}
+
+// NewError reports an operation that failed for any other reason, e.g. reading a local file
+func NewError(operation string, err error) error {
+	return &OperationError{kind: UnknownErrorKind, Operation: operation, Err: err}
+}
@@ -1,5 +1,11 @@
 package types
 
+import (
+	"time"
+
+	"github.com/kahgeh/devenv/provider/manifest"
+)
+
 type AppType string
 
 const (
@@ -7,6 +13,32 @@ const (
 	Api        AppType = "api"
 )
 
+type SessionOptions struct {
+	EnvironmentName string
+	Plan            bool
+	AutoRecover     bool
+	// NamePrefix is prepended to the environment name when naming provider resources
+	NamePrefix string
+	// Fresh ignores the steps an interrupted run of the command completed
+	Fresh bool
+}
+
+// BuildOptions are passed to docker when it builds the image of an app
+type BuildOptions struct {
+	// Dockerfile is relative to the build context, the context Dockerfile when empty
+	Dockerfile string
+	// Target is the stage of a multi-stage Dockerfile to build, the last one when empty
+	Target    string
+	BuildArgs map[string]string
+	NoCache   bool
+	// Pull pulls newer versions of the base images
+	Pull bool
+	// Platform is the os/arch of the image, e.g. linux/arm64, the platform of the docker daemon when empty
+	Platform string
+	// ContextSizeWarning is the size in bytes of the build context over which a warning is printed, 0 never warns
+	ContextSizeWarning int64
+}
+
 type DeployParameters struct {
 	AppType         AppType
 	AppName         string
@@ -14,6 +46,28 @@ type DeployParameters struct {
 	EnvironmentName string
 	DomainName      string
 	DomainEmail     string
+	// Manifest is read from the devenv.yaml in Path, apps without one get the defaults
+	Manifest *manifest.Manifest
+	// Image, or Tag of the app repository, is deployed instead of building the image in Path
+	Image string
+	Tag   string
+	// Build is how the image in Path is built
+	Build BuildOptions
+}
+
+type UndeployParameters struct {
+	AppName         string
+	EnvironmentName string
+	// PurgeImages also deletes the images and the repository of the app
+	PurgeImages bool
+}
+
+// FleetParameters size the spot fleet of the environment
+type FleetParameters struct {
+	InstanceType string
+	SpotPrice    string
+	// Capacity is the number of instances start waits for
+	Capacity int64
 }
 
 type InitialisationParameters struct {
@@ -21,10 +75,133 @@ type InitialisationParameters struct {
 	DomainEmail             string
 	EnvironmentName         string
 	DiscoveryServiceVersion string
+	Fleet                   FleetParameters
 }
 
 type StartParameters struct {
 	HostedZoneName  string
 	DomainName      string
 	EnvironmentName string
+	// StopAt schedules the shutdown of the environment, nil leaves it running till stopped
+	StopAt *time.Time
+	Fleet  FleetParameters
+}
+
+type ExtendShutdownParameters struct {
+	EnvironmentName string
+	// By postpones the scheduled shutdown, unless StopAt is set
+	By     time.Duration
+	StopAt *time.Time
+}
+
+// ShutdownSchedule is when the environment shuts itself down, a nil Deadline means it is not scheduled
+type ShutdownSchedule struct {
+	EnvironmentName string     `json:"environmentName"`
+	Deadline        *time.Time `json:"deadline"`
+	// Passed is set once the deadline passed, the shutdown already fired and nothing is scheduled
+	Passed bool `json:"passed"`
+}
+
+// SecretParameters name a secret of an app, Value is only used when setting it
+type SecretParameters struct {
+	EnvironmentName string
+	AppName         string
+	Key             string
+	Value           string
+}
+
+type ReleasesParameters struct {
+	EnvironmentName string
+	AppName         string
+}
+
+// RollbackParameters name the app and the release it goes back to, the release before the current one when To is empty
+type RollbackParameters struct {
+	EnvironmentName string
+	AppName         string
+	To              string
+	DomainName      string
+	// Manifest is read from the devenv.yaml in the path of the app, it is only used for releases recorded without one
+	Manifest *manifest.Manifest
+}
+
+// Release is an image deployed to an app
+type Release struct {
+	Tag       string    `json:"tag"`
+	Image     string    `json:"image"`
+	Timestamp time.Time `json:"timestamp"`
+	// GitSha is the commit the image was built from, empty when devenv did not build it or the path is not a git repository
+	GitSha   string `json:"gitSha,omitempty"`
+	Deployer string `json:"deployer"`
+	// Manifest is how the app was deployed, a rollback deploys it again, empty for the front proxy and for releases
+	// recorded before it was kept
+	Manifest *manifest.Manifest `json:"manifest,omitempty"`
+	// Current is set on the release the app runs
+	Current bool `json:"current,omitempty"`
+}
+
+type WatchParameters struct {
+	EnvironmentName string
+	// Interval between two checks of the public ip
+	Interval time.Duration
+	// DeployRule deploys a rule that reattaches the public ip without a running watch, RemoveRule removes it
+	DeployRule bool
+	RemoveRule bool
+}
+
+type LogsParameters struct {
+	AppName         string
+	EnvironmentName string
+	// Instance reads the logs of the environment instances instead of an app
+	Instance bool
+	Since    time.Duration
+	Follow   bool
+	Filter   string
+}
+
+type SshParameters struct {
+	EnvironmentName string
+	// Command runs on the instance instead of opening an interactive shell
+	Command []string
+	// WriteConfig adds a host for the environment to ~/.ssh/config instead of connecting
+	WriteConfig bool
+}
+
+type StatusParameters struct {
+	EnvironmentName string
+}
+
+type StackStatus struct {
+	Role   string `json:"role"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type InstanceStatus struct {
+	InstanceID   string `json:"instanceId"`
+	InstanceType string `json:"instanceType"`
+	State        string `json:"state"`
+}
+
+type PublicIPStatus struct {
+	PublicIP   string `json:"publicIp"`
+	InstanceID string `json:"instanceId"`
+	DomainName string `json:"domainName"`
+}
+
+type AppStatus struct {
+	Name         string `json:"name"`
+	StackName    string `json:"stackName"`
+	StackStatus  string `json:"stackStatus"`
+	RunningCount int64  `json:"runningCount"`
+	DesiredCount int64  `json:"desiredCount"`
+}
+
+// EnvironmentStatus is a snapshot of everything provisioned for an environment
+type EnvironmentStatus struct {
+	EnvironmentName string           `json:"environmentName"`
+	Stacks          []StackStatus    `json:"stacks"`
+	Instances       []InstanceStatus `json:"instances"`
+	PublicIP        *PublicIPStatus  `json:"publicIp"`
+	Apps            []AppStatus      `json:"apps"`
 }
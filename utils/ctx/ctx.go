@@ -5,12 +5,23 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
+	"syscall"
 )
 
+// InterruptHandler tidies up operations that carry on without the process, e.g. a stack update running in aws,
+// it runs before the context is cancelled
+type InterruptHandler func(received os.Signal)
+
+// exitInterruptedStatus is the conventional exit code of a process stopped by Ctrl-C
+const exitInterruptedStatus = 130
+
 type ConsoleAppContext struct {
 	ctx       context.Context
 	cancel    context.CancelFunc
 	osSigChan chan os.Signal
+	mutex     sync.Mutex
+	handler   InterruptHandler
 }
 
 var consoleAppCtx *ConsoleAppContext
@@ -24,18 +35,39 @@ func init() {
 		cancel:    cancel,
 		osSigChan: osSigChan}
 
-	signal.Notify(osSigChan, os.Interrupt)
-	// trap Ctrl+C and call cancel on the context
+	// trap Ctrl+C, and SIGTERM sent when a CI job is cancelled, and call cancel on the context
+	signal.Notify(osSigChan, os.Interrupt, syscall.SIGTERM)
 }
 
 func GetContext() context.Context {
 	return consoleAppCtx.ctx
 }
 
+// SetInterruptHandler replaces the handler called on the first interruption
+func SetInterruptHandler(handler InterruptHandler) {
+	consoleAppCtx.mutex.Lock()
+	defer consoleAppCtx.mutex.Unlock()
+	consoleAppCtx.handler = handler
+}
+
+// WaitOnCtrlCSignalOrCompletion lets the interrupt handler tidy up on the first signal and then cancels the context,
+// a second signal exits immediately
 func WaitOnCtrlCSignalOrCompletion() {
 	select {
-	case <-consoleAppCtx.osSigChan:
-		fmt.Println("program terminated because of user cancellation")
+	case received := <-consoleAppCtx.osSigChan:
+		fmt.Fprintln(os.Stderr, "\nprogram interrupted, interrupt again to exit immediately")
+		go func() {
+			<-consoleAppCtx.osSigChan
+			fmt.Fprintln(os.Stderr, "\nprogram terminated because of user cancellation")
+			os.Exit(exitInterruptedStatus)
+		}()
+		consoleAppCtx.mutex.Lock()
+		handler := consoleAppCtx.handler
+		consoleAppCtx.mutex.Unlock()
+		if handler != nil {
+			handler(received)
+		}
+		fmt.Fprintln(os.Stderr, "program terminated because of user cancellation")
 		consoleAppCtx.cancel()
 	case <-consoleAppCtx.ctx.Done():
 	}
@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+)
+
+// Task is a step that runs once the tasks it depends on have succeeded
+type Task struct {
+	Name      string
+	DependsOn []string
+	Run       func(ctx context.Context) error
+}
+
+type taskResult struct {
+	name string
+	err  error
+}
+
+// validate rejects duplicated names, unknown dependencies and cycles, which would leave tasks waiting forever
+func validate(tasks []Task) error {
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, task := range tasks {
+		if _, exists := pending[task.Name]; exists {
+			return fmt.Errorf("task %q is declared more than once", task.Name)
+		}
+		pending[task.Name] = len(task.DependsOn)
+	}
+	var ready []string
+	for _, task := range tasks {
+		for _, dependency := range task.DependsOn {
+			if _, exists := pending[dependency]; !exists {
+				return fmt.Errorf("task %q depends on unknown task %q", task.Name, dependency)
+			}
+			dependents[dependency] = append(dependents[dependency], task.Name)
+		}
+		if len(task.DependsOn) == 0 {
+			ready = append(ready, task.Name)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		visited++
+		for _, dependent := range dependents[name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if visited != len(tasks) {
+		return fmt.Errorf("tasks depend on each other in a cycle")
+	}
+	return nil
+}
+
+// Run runs every task as soon as its dependencies have succeeded, tasks that are ready at the same time run concurrently.
+// The first failure cancels the context of the running tasks and prevents the remaining ones from starting,
+// Run waits for the running tasks to return and then returns that failure
+func Run(parent context.Context, tasks []Task) error {
+	if err := validate(tasks); err != nil {
+		return err
+	}
+	taskCtx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	tasksByName := map[string]Task{}
+	pending := map[string]int{}
+	dependents := map[string][]string{}
+	for _, task := range tasks {
+		tasksByName[task.Name] = task
+		pending[task.Name] = len(task.DependsOn)
+		for _, dependency := range task.DependsOn {
+			dependents[dependency] = append(dependents[dependency], task.Name)
+		}
+	}
+
+	results := make(chan taskResult)
+	running := 0
+	start := func(task Task) {
+		running++
+		go func() {
+			results <- taskResult{name: task.Name, err: task.Run(taskCtx)}
+		}()
+	}
+	for _, task := range tasks {
+		if pending[task.Name] == 0 {
+			start(task)
+		}
+	}
+
+	var failure error
+	for running > 0 {
+		result := <-results
+		running--
+		if failure != nil {
+			continue
+		}
+		if result.err != nil {
+			failure = result.err
+			cancel()
+			continue
+		}
+		if err := taskCtx.Err(); err != nil {
+			failure = err
+			continue
+		}
+		for _, dependent := range dependents[result.name] {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				start(tasksByName[dependent])
+			}
+		}
+	}
+	return failure
+}
@@ -0,0 +1,194 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func noop(context.Context) error {
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		tasks   []Task
+		wantErr string
+	}{
+		{
+			name:  "no tasks",
+			tasks: nil,
+		},
+		{
+			name: "dependencies declared after their dependents",
+			tasks: []Task{
+				{Name: "deploy", DependsOn: []string{"build", "push"}, Run: noop},
+				{Name: "push", DependsOn: []string{"build"}, Run: noop},
+				{Name: "build", Run: noop},
+			},
+		},
+		{
+			name: "duplicated name",
+			tasks: []Task{
+				{Name: "build", Run: noop},
+				{Name: "build", Run: noop},
+			},
+			wantErr: `task "build" is declared more than once`,
+		},
+		{
+			name: "unknown dependency",
+			tasks: []Task{
+				{Name: "deploy", DependsOn: []string{"build"}, Run: noop},
+			},
+			wantErr: `task "deploy" depends on unknown task "build"`,
+		},
+		{
+			name: "task depending on itself",
+			tasks: []Task{
+				{Name: "build", DependsOn: []string{"build"}, Run: noop},
+			},
+			wantErr: "cycle",
+		},
+		{
+			name: "cycle behind a ready task",
+			tasks: []Task{
+				{Name: "vpc", Run: noop},
+				{Name: "fleet", DependsOn: []string{"vpc", "proxy"}, Run: noop},
+				{Name: "proxy", DependsOn: []string{"fleet"}, Run: noop},
+			},
+			wantErr: "cycle",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validate(test.tasks)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() = %v, want no error", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("validate() = %v, want an error containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	errBuild := errors.New("build failed")
+	tests := []struct {
+		name string
+		// tasks gets the function that records the task as started
+		tasks       func(started func(name string)) []Task
+		wantErr     error
+		wantStarted []string
+		wantSkipped []string
+	}{
+		{
+			name: "dependencies run before their dependents",
+			tasks: func(started func(name string)) []Task {
+				return []Task{
+					{Name: "deploy", DependsOn: []string{"push"}, Run: func(context.Context) error {
+						started("deploy")
+						return nil
+					}},
+					{Name: "push", DependsOn: []string{"build"}, Run: func(context.Context) error {
+						started("push")
+						return nil
+					}},
+					{Name: "build", Run: func(context.Context) error {
+						started("build")
+						return nil
+					}},
+				}
+			},
+			wantStarted: []string{"build", "push", "deploy"},
+		},
+		{
+			name: "first failure cancels the running tasks and skips the dependents",
+			tasks: func(started func(name string)) []Task {
+				return []Task{
+					{Name: "build", Run: func(context.Context) error {
+						started("build")
+						return errBuild
+					}},
+					{Name: "lint", Run: func(ctx context.Context) error {
+						started("lint")
+						<-ctx.Done()
+						return ctx.Err()
+					}},
+					{Name: "push", DependsOn: []string{"build"}, Run: func(context.Context) error {
+						started("push")
+						return nil
+					}},
+					{Name: "deploy", DependsOn: []string{"push", "lint"}, Run: func(context.Context) error {
+						started("deploy")
+						return nil
+					}},
+				}
+			},
+			wantErr:     errBuild,
+			wantStarted: []string{"build", "lint"},
+			wantSkipped: []string{"push", "deploy"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mutex sync.Mutex
+			var started []string
+			tasks := test.tasks(func(name string) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				started = append(started, name)
+			})
+			err := Run(context.Background(), tasks)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Run() = %v, want %v", err, test.wantErr)
+			}
+			position := map[string]int{}
+			for i, name := range started {
+				position[name] = i
+			}
+			for _, name := range test.wantStarted {
+				if _, exists := position[name]; !exists {
+					t.Errorf("task %q did not start, started %v", name, started)
+				}
+			}
+			for _, name := range test.wantSkipped {
+				if _, exists := position[name]; exists {
+					t.Errorf("task %q started after the failure, started %v", name, started)
+				}
+			}
+			if test.wantErr == nil {
+				for _, task := range tasks {
+					for _, dependency := range task.DependsOn {
+						if position[dependency] > position[task.Name] {
+							t.Errorf("task %q started before its dependency %q, started %v", task.Name, dependency, started)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRunRejectsInvalidTasksWithoutRunningThem(t *testing.T) {
+	ran := false
+	err := Run(context.Background(), []Task{
+		{Name: "build", Run: func(context.Context) error {
+			ran = true
+			return nil
+		}},
+		{Name: "push", DependsOn: []string{"missing"}, Run: noop},
+	})
+	if err == nil {
+		t.Fatal("Run() = nil, want the validation error")
+	}
+	if ran {
+		t.Error("a task ran although the graph is invalid")
+	}
+}